@@ -0,0 +1,74 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import "slices"
+
+// Occupancy at which the engines switch between scanning every square of the grid (dense) and visiting only the
+// squares on their list of live animals (sparse). A world turns sparse once fewer than 1 square in SparseOccupancy
+// holds an animal and dense again once more than 1 in DenseOccupancy does. The gap between the two stops a
+// population hovering around one threshold from switching back and forth every chronon
+const (
+	SparseOccupancy = 32
+	DenseOccupancy  = 16
+)
+
+// Sparse decides whether a world should visit only its live animals next chronon
+//
+// Parameters:
+//
+//	sparse - whether the world is sparse now
+//	animals - the number of fish and sharks alive
+//	cells - the number of squares in the grid
+//
+// Returns:
+//
+//	bool - true to keep a list of live animals, false to scan the whole grid
+func Sparse(sparse bool, animals int, cells int) bool {
+	if sparse {
+		return animals*DenseOccupancy <= cells
+	}
+	return animals*SparseOccupancy < cells
+}
+
+// Neighbourhood lists every square an animal on one of the given squares can be on one chronon later: the square
+// itself, where it stays or leaves a baby, and its four neighbours, where it can move. Squares are numbered
+// x*height+y, so sorting them puts them in the order the grid is scanned in
+//
+// Parameters:
+//
+//	live - the squares that held an animal, in any order
+//	width - the number of columns in the grid
+//	height - the number of rows in the grid
+//	into - a slice to reuse for the result
+//
+// Returns:
+//
+//	[]int - the squares, sorted with no repeats
+func Neighbourhood(live []int, width int, height int, into []int) []int {
+	into = into[:0]
+	for _, i := range live {
+		x, y := i/height, i%height
+		into = append(into,
+			i,
+			x*height+(y-1+height)%height,
+			((x-1+width)%width)*height+y,
+			((x+1)%width)*height+y,
+			x*height+(y+1)%height)
+	}
+	slices.Sort(into)
+	return slices.Compact(into)
+}
@@ -0,0 +1,294 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Shared Wa-Tor Simulation types in Go
+
+// package watorcommon holds the types shared by the sequential and concurrent Wa-Tor simulations
+package watorcommon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MaxTimer is the largest FishBreed, SharkBreed, Starve or EnergyGain a world accepts. The engines keep breed
+// timers in 16 bits so that a square fits in 8 bytes
+const MaxTimer = math.MaxInt16
+
+// ErrNotPositive reports a parameter that must be at least one but was zero or negative
+var ErrNotPositive = errors.New("must be greater than zero")
+
+// ErrNegative reports a parameter that must not be negative
+var ErrNegative = errors.New("must not be negative")
+
+// ErrTooLarge reports a parameter above MaxTimer
+var ErrTooLarge = errors.New("too large, must be at most 32767")
+
+// ErrTooManyAnimals reports a world that asks for more fish and sharks than it has cells
+var ErrTooManyAnimals = errors.New("more fish and sharks than cells in the grid")
+
+// ErrConflictingSchemes reports a config that asks for more than one update scheme at once
+var ErrConflictingSchemes = errors.New("cannot be combined with Deterministic")
+
+// ErrTooManyThreads reports a concurrent world with more threads than columns, which would leave tiles empty
+var ErrTooManyThreads = errors.New("more threads than columns in the grid")
+
+// ErrUnknownScheduler reports a Scheduler value that is not one of the Scheduler constants
+var ErrUnknownScheduler = errors.New("unknown scheduler")
+
+// ErrTilesTooNarrow reports a world with too many threads for its scheduler. The phased scheduler needs strips at
+// least two columns wide, so that two workers running at the same time never write to the same column, and the
+// halo scheduler needs tiles at least two columns wide, so that every column has one neighbouring tile on each side
+var ErrTilesTooNarrow = errors.New("tiles too narrow for the scheduler, phased needs four columns per thread and " +
+	"halo needs two")
+
+// ConfigError describes one invalid parameter found by Config.Validate
+//
+// Fields:
+//
+//	Field	name of the offending Config field
+//	Value	the value it was given
+//	Err		the rule it broke, one of the Err* values above
+type ConfigError struct {
+	Field string
+	Value int
+	Err   error
+}
+
+// Error formats the field, its value and the rule it broke
+//
+// Returns:
+//
+//	string - the error message
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid config: %s = %d: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap lets errors.Is match a ConfigError against the rule it broke
+//
+// Returns:
+//
+//	error - the rule that was broken
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Scheduler picks how the concurrent engine shares the grid out between its threads
+type Scheduler int
+
+const (
+	// SchedulerLocked splits the grid into blocks and runs them all at once. Writes near the edge of a block take
+	// that block's lock, because the neighbouring blocks can write there too. Whichever worker takes the lock
+	// first gets the square, so runs are not repeatable for a seed, with or without Config.Balance
+	SchedulerLocked Scheduler = iota
+	// SchedulerPhased splits the grid into two strips per thread and runs the even strips, then the odd strips.
+	// Strips running at the same time are never next to each other, so no worker writes where another is
+	// writing and no locks are needed
+	SchedulerPhased
+	// SchedulerHalo runs one column strip per thread, all at once, with no locks. Each worker writes only its own
+	// columns and keeps moves into a neighbouring strip in private halo columns, which are exchanged and reconciled
+	// once every worker has finished its sweep
+	SchedulerHalo
+)
+
+// schedulerNames are the names of the Scheduler constants, in order
+var schedulerNames = []string{"locked", "phased", "halo"}
+
+// String returns the name of the scheduler
+//
+// Returns:
+//
+//	string - "locked", "phased" or "halo", one of schedulerNames
+func (s Scheduler) String() string {
+	if s < 0 || int(s) >= len(schedulerNames) {
+		return fmt.Sprintf("Scheduler(%d)", int(s))
+	}
+	return schedulerNames[s]
+}
+
+// MarshalText returns the name of the scheduler, so a Scheduler can be used with flag.TextVar
+//
+// Returns:
+//
+//	[]byte - the name
+//	error - nil
+func (s Scheduler) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the scheduler from its name
+//
+// Parameters:
+//
+//	text - the name, "locked", "phased" or "halo", one of schedulerNames
+//
+// Returns:
+//
+//	error - if the name is not recognised
+func (s *Scheduler) UnmarshalText(text []byte) error {
+	for i, name := range schedulerNames {
+		if string(text) == name {
+			*s = Scheduler(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q, want one of %v", ErrUnknownScheduler, text, schedulerNames)
+}
+
+// Config holds the parameters a Wa-Tor world is built from.
+//
+// Fields:
+//
+//	Width		the number of columns in the simulation grid
+//	Height		the number of rows in the simulation grid
+//	NumFish		the number of fish the simulation starts with
+//	NumShark	the number of sharks the simulation starts with
+//	FishBreed	the number of simulation steps it takes for a fish to reproduce
+//	SharkBreed	the number of simulation steps it takes for a shark to reproduce
+//	Starve		the number of simulation steps it takes for a shark to starve
+//	EnergyGain	how much energy a shark gains after eating a fish
+//	Threads		the number of threads the simulation runs on when running concurrently
+//	Seed		seeds the random number generator, 0 picks a random seed
+//	Deterministic	resolve conflicting moves by priority instead of by arrival, so that both engines
+//					give identical results for the same seed whatever the number of threads
+//	Asynchronous	move animals one at a time in a random order, changing the grid in place so each move is seen
+//					by the next animal, as in Dewdney's original Wa-Tor, instead of double buffering
+//	Scheduler	how the concurrent engine shares the grid out between its threads. Only SchedulerPhased and
+//				SchedulerHalo give the same run every time for a seed and number of threads. Not saved in
+//				snapshots
+//	TileWidth	the width of the blocks the locked scheduler splits the grid into. Not saved in snapshots
+//	TileHeight	the height of those blocks. Not saved in snapshots. When both are 0 the grid is split into one
+//				block per thread, shaped as close to square as the thread count allows. Otherwise a 0 makes the
+//				blocks as wide or as tall as the grid
+//	Balance		let the locked scheduler's workers take blocks as they finish instead of sweeping a fixed share,
+//				so a worker that runs out of animals helps the busy ones. With automatic tile sizes it makes
+//				several blocks per thread. Not saved in snapshots
+//	Dense		always scan the whole grid, even once a world is sparse enough to visit only its live animals.
+//				A run gives the same result either way, so this is for timing and testing sparse mode. Not
+//				saved in snapshots
+//	Check		verify the invariants with CheckInvariants after every Update and return what is broken as an
+//				error from Update. Slow, meant for debugging the move rules
+type Config struct {
+	Width      int
+	Height     int
+	NumFish    int
+	NumShark   int
+	FishBreed  int
+	SharkBreed int
+	Starve     int
+	EnergyGain int
+	Threads    int
+	Seed       uint64
+
+	Deterministic bool
+	Asynchronous  bool
+	Scheduler     Scheduler
+	TileWidth     int
+	TileHeight    int
+	Balance       bool
+	Dense         bool
+	Check         bool
+}
+
+// DefaultConfig returns the parameters the simulations have always been run with.
+//
+// Returns:
+//
+//	Config - the default simulation parameters
+func DefaultConfig() Config {
+	return Config{
+		Width:      1800,
+		Height:     1000,
+		NumFish:    200000,
+		NumShark:   100,
+		FishBreed:  5,
+		SharkBreed: 10,
+		Starve:     4,
+		EnergyGain: 2,
+		Threads:    8,
+	}
+}
+
+// Validate checks every parameter before a simulation is started so that bad input is reported instead of
+// panicking part way through setting up the grid
+//
+// Returns:
+//
+//	error - nil if the config is usable, otherwise every problem found joined together. Each one is a *ConfigError
+func (c Config) Validate() error {
+	var errs []error
+	positive := []struct {
+		field string
+		value int
+	}{
+		{"Width", c.Width},
+		{"Height", c.Height},
+		{"FishBreed", c.FishBreed},
+		{"SharkBreed", c.SharkBreed},
+		{"Starve", c.Starve},
+		{"Threads", c.Threads},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, &ConfigError{Field: p.field, Value: p.value, Err: ErrNotPositive})
+		}
+	}
+	nonNegative := []struct {
+		field string
+		value int
+	}{
+		{"NumFish", c.NumFish},
+		{"NumShark", c.NumShark},
+		{"EnergyGain", c.EnergyGain},
+		{"TileWidth", c.TileWidth},
+		{"TileHeight", c.TileHeight},
+	}
+	for _, p := range nonNegative {
+		if p.value < 0 {
+			errs = append(errs, &ConfigError{Field: p.field, Value: p.value, Err: ErrNegative})
+		}
+	}
+	timers := []struct {
+		field string
+		value int
+	}{
+		{"FishBreed", c.FishBreed},
+		{"SharkBreed", c.SharkBreed},
+		{"Starve", c.Starve},
+		{"EnergyGain", c.EnergyGain},
+	}
+	for _, p := range timers {
+		if p.value > MaxTimer {
+			errs = append(errs, &ConfigError{Field: p.field, Value: p.value, Err: ErrTooLarge})
+		}
+	}
+	if c.Width > 0 && c.Height > 0 && c.NumFish+c.NumShark > c.Width*c.Height {
+		errs = append(errs, &ConfigError{Field: "NumFish+NumShark", Value: c.NumFish + c.NumShark, Err: ErrTooManyAnimals})
+	}
+	if c.Width > 0 && c.Threads > c.Width {
+		errs = append(errs, &ConfigError{Field: "Threads", Value: c.Threads, Err: ErrTooManyThreads})
+	}
+	if c.Scheduler < 0 || int(c.Scheduler) >= len(schedulerNames) {
+		errs = append(errs, &ConfigError{Field: "Scheduler", Value: int(c.Scheduler), Err: ErrUnknownScheduler})
+	} else if c.Scheduler == SchedulerPhased && c.Threads > 0 && 4*c.Threads > c.Width ||
+		c.Scheduler == SchedulerHalo && c.Threads > 0 && 2*c.Threads > c.Width {
+		errs = append(errs, &ConfigError{Field: "Threads", Value: c.Threads, Err: ErrTilesTooNarrow})
+	}
+	if c.Deterministic && c.Asynchronous {
+		errs = append(errs, &ConfigError{Field: "Asynchronous", Value: 1, Err: ErrConflictingSchemes})
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	watorcommon "help/common"
+)
+
+// configErrors lists the ConfigErrors joined together in an error returned by Validate
+//
+// Parameters:
+//
+//	t - the test, failed if err holds anything but ConfigErrors
+//	err - the error, may be nil
+//
+// Returns:
+//
+//	[]watorcommon.ConfigError - each ConfigError in the order Validate found them
+func configErrors(t *testing.T, err error) []watorcommon.ConfigError {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %v, want errors joined together", err)
+	}
+	var found []watorcommon.ConfigError
+	for _, err := range joined.Unwrap() {
+		var configErr *watorcommon.ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("got %v, want a ConfigError", err)
+		}
+		found = append(found, *configErr)
+	}
+	return found
+}
+
+// TestValidate breaks each rule in turn and checks that Validate reports the field, its value and the rule, and
+// that errors.Is matches the rule
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *watorcommon.Config)
+		want   []watorcommon.ConfigError
+	}{
+		{"valid", func(c *watorcommon.Config) {}, nil},
+		{"zero width", func(c *watorcommon.Config) { c.Width, c.Scheduler = 0, watorcommon.SchedulerLocked },
+			[]watorcommon.ConfigError{{"Width", 0, watorcommon.ErrNotPositive}}},
+		{"negative height", func(c *watorcommon.Config) { c.Height = -1 },
+			[]watorcommon.ConfigError{{"Height", -1, watorcommon.ErrNotPositive}}},
+		{"zero fish breed", func(c *watorcommon.Config) { c.FishBreed = 0 },
+			[]watorcommon.ConfigError{{"FishBreed", 0, watorcommon.ErrNotPositive}}},
+		{"negative shark breed", func(c *watorcommon.Config) { c.SharkBreed = -2 },
+			[]watorcommon.ConfigError{{"SharkBreed", -2, watorcommon.ErrNotPositive}}},
+		{"zero starve", func(c *watorcommon.Config) { c.Starve = 0 },
+			[]watorcommon.ConfigError{{"Starve", 0, watorcommon.ErrNotPositive}}},
+		{"zero threads", func(c *watorcommon.Config) { c.Threads = 0 },
+			[]watorcommon.ConfigError{{"Threads", 0, watorcommon.ErrNotPositive}}},
+		{"negative fish", func(c *watorcommon.Config) { c.NumFish = -1 },
+			[]watorcommon.ConfigError{{"NumFish", -1, watorcommon.ErrNegative}}},
+		{"negative sharks", func(c *watorcommon.Config) { c.NumShark = -1 },
+			[]watorcommon.ConfigError{{"NumShark", -1, watorcommon.ErrNegative}}},
+		{"negative energy gain", func(c *watorcommon.Config) { c.EnergyGain = -1 },
+			[]watorcommon.ConfigError{{"EnergyGain", -1, watorcommon.ErrNegative}}},
+		{"negative tile width", func(c *watorcommon.Config) { c.TileWidth = -1 },
+			[]watorcommon.ConfigError{{"TileWidth", -1, watorcommon.ErrNegative}}},
+		{"negative tile height", func(c *watorcommon.Config) { c.TileHeight = -1 },
+			[]watorcommon.ConfigError{{"TileHeight", -1, watorcommon.ErrNegative}}},
+		{"fish breed too large", func(c *watorcommon.Config) { c.FishBreed = watorcommon.MaxTimer + 1 },
+			[]watorcommon.ConfigError{{"FishBreed", watorcommon.MaxTimer + 1, watorcommon.ErrTooLarge}}},
+		{"shark breed too large", func(c *watorcommon.Config) { c.SharkBreed = watorcommon.MaxTimer + 1 },
+			[]watorcommon.ConfigError{{"SharkBreed", watorcommon.MaxTimer + 1, watorcommon.ErrTooLarge}}},
+		{"starve too large", func(c *watorcommon.Config) { c.Starve = watorcommon.MaxTimer + 1 },
+			[]watorcommon.ConfigError{{"Starve", watorcommon.MaxTimer + 1, watorcommon.ErrTooLarge}}},
+		{"energy gain too large", func(c *watorcommon.Config) { c.EnergyGain = watorcommon.MaxTimer + 1 },
+			[]watorcommon.ConfigError{{"EnergyGain", watorcommon.MaxTimer + 1, watorcommon.ErrTooLarge}}},
+		{"too many animals", func(c *watorcommon.Config) { c.NumFish = 1141 },
+			[]watorcommon.ConfigError{{"NumFish+NumShark", 1201, watorcommon.ErrTooManyAnimals}}},
+		{"more threads than columns",
+			func(c *watorcommon.Config) { c.Threads, c.Scheduler = 41, watorcommon.SchedulerLocked },
+			[]watorcommon.ConfigError{{"Threads", 41, watorcommon.ErrTooManyThreads}}},
+		{"unknown scheduler", func(c *watorcommon.Config) { c.Scheduler = 3 },
+			[]watorcommon.ConfigError{{"Scheduler", 3, watorcommon.ErrUnknownScheduler}}},
+		{"negative scheduler", func(c *watorcommon.Config) { c.Scheduler = -1 },
+			[]watorcommon.ConfigError{{"Scheduler", -1, watorcommon.ErrUnknownScheduler}}},
+		{"deterministic and asynchronous", func(c *watorcommon.Config) { c.Deterministic, c.Asynchronous = true, true },
+			[]watorcommon.ConfigError{{"Asynchronous", 1, watorcommon.ErrConflictingSchemes}}},
+		{"every problem reported", func(c *watorcommon.Config) { c.Starve, c.NumShark = 0, -5 },
+			[]watorcommon.ConfigError{
+				{"Starve", 0, watorcommon.ErrNotPositive},
+				{"NumShark", -5, watorcommon.ErrNegative},
+			}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testConfig()
+			test.change(&config)
+			err := config.Validate()
+			if got := configErrors(t, err); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for _, want := range test.want {
+				if !errors.Is(err, want.Err) {
+					t.Errorf("errors.Is(%v, %v) is false", err, want.Err)
+				}
+			}
+		})
+	}
+}
+
+// TestValidateTilesTooNarrow checks the narrowest tiles each scheduler accepts, and that one more thread is refused
+func TestValidateTilesTooNarrow(t *testing.T) {
+	tests := []struct {
+		scheduler watorcommon.Scheduler
+		threads   int
+		narrow    bool
+	}{
+		{watorcommon.SchedulerPhased, 10, false},
+		{watorcommon.SchedulerPhased, 11, true},
+		{watorcommon.SchedulerHalo, 20, false},
+		{watorcommon.SchedulerHalo, 21, true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%d threads", test.scheduler, test.threads), func(t *testing.T) {
+			config := testConfig()
+			config.Width, config.Scheduler, config.Threads = 40, test.scheduler, test.threads
+			err := config.Validate()
+			if got := errors.Is(err, watorcommon.ErrTilesTooNarrow); got != test.narrow {
+				t.Fatalf("got %v, want ErrTilesTooNarrow %t", err, test.narrow)
+			}
+			var configErr *watorcommon.ConfigError
+			if test.narrow && (!errors.As(err, &configErr) || configErr.Field != "Threads" || configErr.Value != test.threads) {
+				t.Errorf("got %v, want a ConfigError for Threads = %d", err, test.threads)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnbounded is returned by RunHeadless when it is given neither a chronon limit nor a stop condition
+var ErrUnbounded = errors.New("headless run needs a chronon limit or a stop condition")
+
+// Engine is what the headless runner needs from a world. Both the sequential and concurrent World satisfy it
+type Engine interface {
+	Grid
+	Update() error
+	Chronon() int
+	Population() (fish int, sharks int)
+	Stats() Stats
+}
+
+// Observer is told about every chronon of a headless run, after Update has returned
+type Observer interface {
+	Observe(engine Engine) error
+}
+
+// StopFunc is checked after every chronon of a headless run. Returning true ends the run
+type StopFunc func(engine Engine) bool
+
+// Extinct is a StopFunc that ends a run once either the fish or the sharks have died out
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	bool - true if there are no fish or no sharks left
+func Extinct(engine Engine) bool {
+	fish, sharks := engine.Population()
+	return fish == 0 || sharks == 0
+}
+
+// Result is what a headless run reports once it finishes
+//
+// Fields:
+//
+//	Chronons	the number of chronons this run advanced the world by
+//	Fish		the number of fish left at the end of the run
+//	Sharks		the number of sharks left at the end of the run
+//	Stopped		true if the stop condition ended the run before the chronon limit
+//	Elapsed		wall time the run took
+type Result struct {
+	Chronons int
+	Fish     int
+	Sharks   int
+	Stopped  bool
+	Elapsed  time.Duration
+}
+
+// RunHeadless advances a world without opening a window, calling Update until the chronon limit is reached or
+// the stop condition returns true, whichever comes first
+//
+// Parameters:
+//
+//	engine - the world to run
+//	chronons - how many chronons to run for, 0 or less for no limit
+//	stop - checked after every chronon, may be nil
+//	observers - told about every chronon, before stop is checked
+//
+// Returns:
+//
+//	Result - the final population and timing of the run
+//	error - ErrUnbounded if there is nothing to end the run, or the first error returned by Update or an observer
+func RunHeadless(engine Engine, chronons int, stop StopFunc, observers ...Observer) (Result, error) {
+	if chronons <= 0 && stop == nil {
+		return Result{}, ErrUnbounded
+	}
+	var result Result
+	start := time.Now()
+	for chronons <= 0 || result.Chronons < chronons {
+		if err := engine.Update(); err != nil {
+			result.Elapsed = time.Since(start)
+			return result, err
+		}
+		result.Chronons++
+		for _, observer := range observers {
+			if err := observer.Observe(engine); err != nil {
+				result.Elapsed = time.Since(start)
+				return result, err
+			}
+		}
+		if stop != nil && stop(engine) {
+			result.Stopped = true
+			break
+		}
+	}
+	result.Elapsed = time.Since(start)
+	result.Fish, result.Sharks = engine.Population()
+	return result, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// The colours both engines draw the world in
+var (
+	Blue   = color.RGBA{69, 145, 196, 255}
+	Yellow = color.RGBA{255, 230, 120, 255}
+	Red    = color.RGBA{255, 50, 50, 255}
+)
+
+// Palette maps a typeId to the colour it is drawn in: blue water, yellow fish and red sharks
+var Palette = [3]color.RGBA{Blue, Yellow, Red}
+
+// TypeOfColour returns the typeId whose Palette colour is closest to c, so images that were resized or saved
+// with slightly different colours still load as intended
+//
+// Parameters:
+//
+//	c - the colour of one pixel
+//
+// Returns:
+//
+//	int - 0 = empty space, 1 = fish, 2 = shark
+func TypeOfColour(c color.Color) int {
+	r, g, b, _ := c.RGBA()
+	best, bestDistance := 0, -1
+	for typeId, colour := range Palette {
+		dr := int(r>>8) - int(colour.R)
+		dg := int(g>>8) - int(colour.G)
+		db := int(b>>8) - int(colour.B)
+		if distance := dr*dr + dg*dg + db*db; bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = typeId, distance
+		}
+	}
+	return best
+}
+
+// SnapshotFromImage builds a starting world from an image, one square per pixel. Each pixel becomes water, a fish
+// or a shark depending on which Palette colour it is closest to. Fish start with a full breedTimer and sharks
+// with a full breedTimer and energy, just as NewWorld places them. Either engine's NewWorldFromSnapshot turns the
+// result into a world
+//
+// Parameters:
+//
+//	img - the starting world
+//	config - the simulation parameters. Width, Height, NumFish and NumShark are replaced by what the image holds
+//
+// Returns:
+//
+//	*Snapshot - the world at chronon 0, with no random number state so the engine derives it from the seed
+//	error - a *ConfigError for each invalid parameter, nil otherwise
+func SnapshotFromImage(img image.Image, config Config) (*Snapshot, error) {
+	bounds := img.Bounds()
+	config.Width = bounds.Dx()
+	config.Height = bounds.Dy()
+	config.NumFish = 0
+	config.NumShark = 0
+	snapshot := &Snapshot{Cells: make([]Cell, config.Width*config.Height)}
+	for x := 0; x < config.Width; x++ {
+		for y := 0; y < config.Height; y++ {
+			cell := &snapshot.Cells[x*config.Height+y]
+			cell.TypeId = TypeOfColour(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			if cell.TypeId == 1 {
+				cell.BreedTimer = config.FishBreed
+				config.NumFish++
+			} else if cell.TypeId == 2 {
+				cell.BreedTimer = config.SharkBreed
+				cell.Energy = config.Starve
+				config.NumShark++
+			}
+		}
+	}
+	snapshot.Config = config
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// LoadImage decodes a PNG and builds a starting world from it with SnapshotFromImage
+//
+// Parameters:
+//
+//	r - where the PNG is read from
+//	config - the simulation parameters. Width, Height, NumFish and NumShark are replaced by what the image holds
+//
+// Returns:
+//
+//	*Snapshot - the world at chronon 0
+//	error - if the PNG cannot be decoded or the parameters are invalid
+func LoadImage(r io.Reader, config Config) (*Snapshot, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return SnapshotFromImage(img, config)
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxInvariantErrors caps how many broken squares CheckInvariants reports, so a badly broken grid does not
+// produce millions of errors
+const maxInvariantErrors = 20
+
+// ErrInvariant is matched by every InvariantError
+var ErrInvariant = errors.New("invariant violated")
+
+// InvariantError describes one invariant that did not hold after an Update
+//
+// Fields:
+//
+//	Chronon		the chronon that had just been computed
+//	X			x coordinate of the offending square, -1 if the problem is not with one square
+//	Y			y coordinate of the offending square, -1 if the problem is not with one square
+//	Problem		what was wrong
+type InvariantError struct {
+	Chronon int
+	X       int
+	Y       int
+	Problem string
+}
+
+// Error formats the chronon, the square if there is one and the problem
+//
+// Returns:
+//
+//	string - the error message
+func (e *InvariantError) Error() string {
+	if e.X < 0 {
+		return fmt.Sprintf("invariant violated in chronon %d: %s", e.Chronon, e.Problem)
+	}
+	return fmt.Sprintf("invariant violated in chronon %d at (%d, %d): %s", e.Chronon, e.X, e.Y, e.Problem)
+}
+
+// Unwrap lets errors.Is match any InvariantError against ErrInvariant
+//
+// Returns:
+//
+//	error - ErrInvariant
+func (e *InvariantError) Unwrap() error {
+	return ErrInvariant
+}
+
+// CheckCell checks the invariants of a single square: typeId is 0, 1 or 2, a live shark has energy left, and a
+// fish or shark's breedTimer lies between 1 and its breeding time
+//
+// Parameters:
+//
+//	cell - the square to check
+//	fishBreed - the number of chronons it takes for a fish to reproduce
+//	sharkBreed - the number of chronons it takes for a shark to reproduce
+//
+// Returns:
+//
+//	string - what is wrong with the square, empty if nothing is
+func CheckCell(cell Cell, fishBreed int, sharkBreed int) string {
+	switch cell.TypeId {
+	case 0:
+	case 1:
+		if cell.BreedTimer < 1 || cell.BreedTimer > fishBreed {
+			return fmt.Sprintf("fish has breedTimer %d, want 1 to %d", cell.BreedTimer, fishBreed)
+		}
+	case 2:
+		if cell.Energy <= 0 {
+			return fmt.Sprintf("live shark has energy %d", cell.Energy)
+		}
+		if cell.BreedTimer < 1 || cell.BreedTimer > sharkBreed {
+			return fmt.Sprintf("shark has breedTimer %d, want 1 to %d", cell.BreedTimer, sharkBreed)
+		}
+	default:
+		return fmt.Sprintf("typeId is %d, want 0, 1 or 2", cell.TypeId)
+	}
+	return ""
+}
+
+// CheckLedger checks that the populations changed by exactly the events counted in stats:
+// fish before + fish births - predations == fish after, and sharks before + shark births - starvations == sharks
+// after
+//
+// Parameters:
+//
+//	fishBefore - the number of fish before the Update
+//	sharksBefore - the number of sharks before the Update
+//	stats - the stats of the Update, with Fish and Sharks counted after it
+//
+// Returns:
+//
+//	[]string - one entry for each population that does not balance, empty if both do
+func CheckLedger(fishBefore int, sharksBefore int, stats Stats) []string {
+	var problems []string
+	if want := fishBefore + stats.FishBirths - stats.Predations; stats.Fish != want {
+		problems = append(problems, fmt.Sprintf("%d fish + %d births - %d eaten = %d, but there are %d fish",
+			fishBefore, stats.FishBirths, stats.Predations, want, stats.Fish))
+	}
+	if want := sharksBefore + stats.SharkBirths - stats.Starvations; stats.Sharks != want {
+		problems = append(problems, fmt.Sprintf("%d sharks + %d births - %d starved = %d, but there are %d sharks",
+			sharksBefore, stats.SharkBirths, stats.Starvations, want, stats.Sharks))
+	}
+	return problems
+}
+
+// CheckInvariants checks every square of a grid with CheckCell and the populations with CheckLedger. Engines call
+// it at the end of Update when Config.Check is set
+//
+// Parameters:
+//
+//	grid - the world after the Update
+//	fishBreed - the number of chronons it takes for a fish to reproduce
+//	sharkBreed - the number of chronons it takes for a shark to reproduce
+//	fishBefore - the number of fish before the Update
+//	sharksBefore - the number of sharks before the Update
+//	stats - the stats of the Update, with Fish and Sharks counted after it
+//
+// Returns:
+//
+//	error - an *InvariantError for each problem found, up to a limit, joined together. nil if every invariant holds
+func CheckInvariants(grid Grid, fishBreed int, sharkBreed int, fishBefore int, sharksBefore int, stats Stats) error {
+	var errs []error
+	width, height := grid.Size()
+	broken := 0
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if problem := CheckCell(grid.Cell(x, y), fishBreed, sharkBreed); problem != "" {
+				broken++
+				if broken <= maxInvariantErrors {
+					errs = append(errs, &InvariantError{Chronon: stats.Chronon, X: x, Y: y, Problem: problem})
+				}
+			}
+		}
+	}
+	if broken > maxInvariantErrors {
+		errs = append(errs, &InvariantError{Chronon: stats.Chronon, X: -1, Y: -1,
+			Problem: fmt.Sprintf("%d more squares are broken", broken-maxInvariantErrors)})
+	}
+	for _, problem := range CheckLedger(fishBefore, sharksBefore, stats) {
+		errs = append(errs, &InvariantError{Chronon: stats.Chronon, X: -1, Y: -1, Problem: problem})
+	}
+	return errors.Join(errs...)
+}
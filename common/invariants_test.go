@@ -0,0 +1,119 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon_test
+
+import (
+	"errors"
+	"testing"
+
+	watorcommon "help/common"
+)
+
+// cellGrid is a grid held as a slice of cells numbered x*height+y, the way snapshots store them
+type cellGrid struct {
+	width  int
+	height int
+	cells  []watorcommon.Cell
+}
+
+// Size returns the width and height of the grid
+func (g cellGrid) Size() (int, int) {
+	return g.width, g.height
+}
+
+// Cell returns the cell at x, y
+func (g cellGrid) Cell(x int, y int) watorcommon.Cell {
+	return g.cells[x*g.height+y]
+}
+
+// TestCheckCell checks that CheckCell passes valid squares and reports each kind of broken one
+func TestCheckCell(t *testing.T) {
+	tests := []struct {
+		name   string
+		cell   watorcommon.Cell
+		broken bool
+	}{
+		{"water", watorcommon.Cell{}, false},
+		{"fish", watorcommon.Cell{TypeId: 1, BreedTimer: 5}, false},
+		{"shark", watorcommon.Cell{TypeId: 2, Energy: 1, BreedTimer: 1}, false},
+		{"unknown type", watorcommon.Cell{TypeId: 3, BreedTimer: 1}, true},
+		{"negative type", watorcommon.Cell{TypeId: -1}, true},
+		{"fish breedTimer 0", watorcommon.Cell{TypeId: 1}, true},
+		{"fish breedTimer past FishBreed", watorcommon.Cell{TypeId: 1, BreedTimer: 6}, true},
+		{"shark with no energy", watorcommon.Cell{TypeId: 2, BreedTimer: 3}, true},
+		{"shark with negative energy", watorcommon.Cell{TypeId: 2, Energy: -2, BreedTimer: 3}, true},
+		{"shark breedTimer 0", watorcommon.Cell{TypeId: 2, Energy: 3}, true},
+		{"shark breedTimer past SharkBreed", watorcommon.Cell{TypeId: 2, Energy: 3, BreedTimer: 11}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if problem := watorcommon.CheckCell(test.cell, 5, 10); (problem != "") != test.broken {
+				t.Errorf("got problem %q, want broken %v", problem, test.broken)
+			}
+		})
+	}
+}
+
+// TestCheckLedger checks that CheckLedger reports each population that changed by more or less than the events
+// counted
+func TestCheckLedger(t *testing.T) {
+	tests := []struct {
+		name         string
+		fishBefore   int
+		sharksBefore int
+		stats        watorcommon.Stats
+		problems     int
+	}{
+		{"balanced", 100, 20, watorcommon.Stats{Fish: 105, Sharks: 21, FishBirths: 8, SharkBirths: 3, Predations: 3,
+			Starvations: 2}, 0},
+		{"ghost fish", 100, 20, watorcommon.Stats{Fish: 100, Sharks: 20, Predations: 2}, 1},
+		{"overwritten shark", 100, 20, watorcommon.Stats{Fish: 100, Sharks: 19}, 1},
+		{"overwritten fish and shark", 100, 20, watorcommon.Stats{Fish: 99, Sharks: 19}, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problems := watorcommon.CheckLedger(test.fishBefore, test.sharksBefore, test.stats)
+			if len(problems) != test.problems {
+				t.Errorf("got problems %q, want %d", problems, test.problems)
+			}
+		})
+	}
+}
+
+// TestCheckInvariants checks that a broken grid and ledger come back as InvariantErrors naming the chronon and the
+// square
+func TestCheckInvariants(t *testing.T) {
+	grid := cellGrid{width: 2, height: 3, cells: make([]watorcommon.Cell, 6)}
+	grid.cells[0] = watorcommon.Cell{TypeId: 1, BreedTimer: 2}
+	stats := watorcommon.Stats{Chronon: 7, Fish: 1}
+	if err := watorcommon.CheckInvariants(grid, 5, 10, 1, 0, stats); err != nil {
+		t.Fatalf("valid grid: %v", err)
+	}
+	// A shark with no energy at (1, 2), which the ledger does not expect either
+	grid.cells[1*3+2] = watorcommon.Cell{TypeId: 2, BreedTimer: 1}
+	stats.Sharks = 1
+	err := watorcommon.CheckInvariants(grid, 5, 10, 1, 0, stats)
+	if !errors.Is(err, watorcommon.ErrInvariant) {
+		t.Fatalf("got %v, want %v", err, watorcommon.ErrInvariant)
+	}
+	var square *watorcommon.InvariantError
+	if !errors.As(err, &square) || square.Chronon != 7 || square.X != 1 || square.Y != 2 {
+		t.Errorf("got %+v, want chronon 7 at (1, 2)", square)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("got %v, want the square and the ledger reported", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import "math/rand/v2"
+
+// PickSeed returns the seed a world should use. A seed of 0 asks for a fresh random seed, anything else is kept
+// so that runs can be reproduced
+//
+// Parameters:
+//
+//	seed - the seed from the config
+//
+// Returns:
+//
+//	uint64 - the seed to use, never 0
+func PickSeed(seed uint64) uint64 {
+	for seed == 0 {
+		seed = rand.Uint64()
+	}
+	return seed
+}
+
+// NewSource returns a reproducible random number source. The same seed and stream always give the same
+// sequence, and different streams of one seed give independent sequences, so every worker of a world can
+// have its own
+//
+// Parameters:
+//
+//	seed - the world's seed
+//	stream - which stream of that seed to return
+//
+// Returns:
+//
+//	*rand.PCG - the random number source
+func NewSource(seed uint64, stream uint64) *rand.PCG {
+	return rand.NewPCG(seed, mix(stream))
+}
+
+// NewResumeSource returns the source an engine uses when it resumes a snapshot whose random number states do not
+// match its own workers, for example a sequential snapshot resumed by the concurrent engine. The sequence depends
+// only on the seed, chronon and stream, so resuming the same snapshot twice gives the same run
+//
+// Parameters:
+//
+//	seed - the world's seed
+//	chronon - the chronon the snapshot was taken at
+//	stream - which stream of that seed to return
+//
+// Returns:
+//
+//	*rand.PCG - the random number source
+func NewResumeSource(seed uint64, chronon int, stream uint64) *rand.PCG {
+	return rand.NewPCG(seed^mix(uint64(chronon)), mix(stream))
+}
+
+// CellRandom returns a random number fixed entirely by its inputs. The deterministic update draws every choice
+// for a square from it, so the result never depends on which thread handles the square or in which order
+//
+// Parameters:
+//
+//	seed - the world's seed
+//	chronon - the chronon being computed
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//
+// Returns:
+//
+//	uint64 - the random number for that square in that chronon
+func CellRandom(seed uint64, chronon int, x int, y int) uint64 {
+	return mix(seed ^ mix(uint64(chronon)^mix(uint64(x)<<32|uint64(y))))
+}
+
+// CellPriority returns the priority the animal on a square has in the given chronon. When several animals want
+// the same square the one with the highest priority gets it
+//
+// Parameters:
+//
+//	seed - the world's seed
+//	chronon - the chronon being computed
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//
+// Returns:
+//
+//	uint64 - the priority, independent of the number CellRandom returns for the same square
+func CellPriority(seed uint64, chronon int, x int, y int) uint64 {
+	return mix(CellRandom(seed, chronon, x, y))
+}
+
+// mix scrambles a stream number with the splitmix64 finaliser so that neighbouring streams start from
+// unrelated states
+//
+// Parameters:
+//
+//	x - the value to scramble
+//
+// Returns:
+//
+//	uint64 - the scrambled value
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,282 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrFramePattern is returned by NewPNGWriter for a file name pattern that does not hold exactly one integer verb
+var ErrFramePattern = errors.New("frame file name needs exactly one integer verb for the chronon, such as %06d")
+
+// Scale defines the drawing scale for each cell, in the windows and in rendered images alike
+const Scale = 1
+
+// gifPalette lists the Palette colours in typeId order, so a paletted pixel's index is the typeId of its square
+var gifPalette = color.Palette{Blue, Yellow, Red}
+
+// Grid is anything whose squares can be read one at a time. Both engines' Worlds are Grids
+type Grid interface {
+	Size() (width int, height int)
+	Cell(x int, y int) Cell
+}
+
+// Render draws a grid into an RGBA image in the Palette colours, each square as a scale x scale block
+//
+// Parameters:
+//
+//	img - the image to draw into, reused if it is already the right size, may be nil
+//	grid - the world to draw
+//	scale - the size of each square in pixels
+//
+// Returns:
+//
+//	*image.RGBA - img, or a new image if img was nil or the wrong size
+func Render(img *image.RGBA, grid Grid, scale int) *image.RGBA {
+	width, height := grid.Size()
+	bounds := image.Rect(0, 0, width*scale, height*scale)
+	if img == nil || img.Bounds() != bounds {
+		img = image.NewRGBA(bounds)
+	}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			PaintSquare(img.Pix, img.Stride, x, y, scale, grid.Cell(x, y).TypeId)
+		}
+	}
+	return img
+}
+
+// PaintSquare colours the scale x scale block of an RGBA pixel buffer that shows the square at (x, y) in the
+// Palette colour of typeId. Different squares never share a byte, so separate threads can paint separate squares
+// of the same buffer
+//
+// Parameters:
+//
+//	pixels - RGBA bytes, row by row, starting at the top left corner of the grid
+//	stride - the number of bytes in one row of pixels
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//	scale - the size of each square in pixels
+//	typeId - 0 = empty space, 1 = fish, 2 = shark
+func PaintSquare(pixels []byte, stride int, x int, y int, scale int, typeId int) {
+	colour := Palette[typeId]
+	for j := 0; j < scale; j++ {
+		offset := (y*scale+j)*stride + x*scale*4
+		for i := 0; i < scale; i++ {
+			pixel := pixels[offset+4*i : offset+4*i+4 : offset+4*i+4]
+			pixel[0], pixel[1], pixel[2], pixel[3] = colour.R, colour.G, colour.B, colour.A
+		}
+	}
+}
+
+// RenderPaletted draws a grid into a paletted image, each square as a scale x scale block. The palette holds the
+// three Palette colours, which is all a GIF frame needs
+//
+// Parameters:
+//
+//	grid - the world to draw
+//	scale - the size of each square in pixels
+//
+// Returns:
+//
+//	*image.Paletted - the new image
+func RenderPaletted(grid Grid, scale int) *image.Paletted {
+	width, height := grid.Size()
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), gifPalette)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			typeId := uint8(grid.Cell(x, y).TypeId)
+			for j := 0; j < scale; j++ {
+				offset := img.PixOffset(x*scale, y*scale+j)
+				for i := 0; i < scale; i++ {
+					img.Pix[offset+i] = typeId
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNGWriter saves a PNG of the world every few chronons. It is an Observer, so it can be passed to RunHeadless
+type PNGWriter struct {
+	pattern string
+	every   int
+	scale   int
+	image   *image.RGBA
+}
+
+// NewPNGWriter returns a PNGWriter that names each frame after the chronon it shows
+//
+// Parameters:
+//
+//	pattern - the file name of each frame, with a %d verb for the chronon, for example "frames/wator-%06d.png"
+//	every - how many chronons apart frames are saved, 1 or less for every chronon
+//	scale - the size of each square in pixels
+//
+// Returns:
+//
+//	*PNGWriter - the writer
+//	error - an ErrFramePattern if pattern does not hold exactly one integer verb
+func NewPNGWriter(pattern string, every int, scale int) (*PNGWriter, error) {
+	if err := checkFramePattern(pattern); err != nil {
+		return nil, err
+	}
+	return &PNGWriter{pattern: pattern, every: max(every, 1), scale: scale}, nil
+}
+
+// checkFramePattern checks that a frame file name pattern holds one verb that prints an integer, with any flags,
+// width and precision, and no verbs besides %%. Argument indexes and * widths are rejected
+//
+// Parameters:
+//
+//	pattern - the file name pattern
+//
+// Returns:
+//
+//	error - an ErrFramePattern naming the pattern, nil if it is usable
+func checkFramePattern(pattern string) error {
+	verbs := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(pattern) && strings.IndexByte("+-# 0123456789.", pattern[i]) >= 0 {
+			i++
+		}
+		switch {
+		case i == len(pattern):
+			return fmt.Errorf("%w, got %q", ErrFramePattern, pattern)
+		case pattern[i] == '%':
+		case strings.IndexByte("bdoOxX", pattern[i]) >= 0:
+			verbs++
+		default:
+			return fmt.Errorf("%w, got %q", ErrFramePattern, pattern)
+		}
+	}
+	if verbs != 1 {
+		return fmt.Errorf("%w, got %q", ErrFramePattern, pattern)
+	}
+	return nil
+}
+
+// Write saves one frame, whatever the chronon
+//
+// Parameters:
+//
+//	grid - the world to draw
+//	chronon - the chronon used in the file name
+//
+// Returns:
+//
+//	error - if the file cannot be written
+func (p *PNGWriter) Write(grid Grid, chronon int) error {
+	p.image = Render(p.image, grid, p.scale)
+	file, err := os.Create(fmt.Sprintf(p.pattern, chronon))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, p.image); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Observe saves a frame if the chronon the engine has just finished is one of the chronons being saved
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	error - if the file cannot be written
+func (p *PNGWriter) Observe(engine Engine) error {
+	if engine.Chronon()%p.every != 0 {
+		return nil
+	}
+	return p.Write(engine, engine.Chronon())
+}
+
+// GIFWriter collects a frame every few chronons and writes them all as one animated GIF when it is closed. It is
+// an Observer, so it can be passed to RunHeadless. Every frame is held in memory until Close, one byte per pixel
+type GIFWriter struct {
+	w         io.Writer
+	every     int
+	scale     int
+	delay     int
+	animation gif.GIF
+}
+
+// NewGIFWriter returns a GIFWriter that writes to w once it is closed
+//
+// Parameters:
+//
+//	w - where the GIF is written
+//	every - how many chronons apart frames are taken, 1 or less for every chronon
+//	scale - the size of each square in pixels
+//	delay - how long each frame is shown, in hundredths of a second
+//
+// Returns:
+//
+//	*GIFWriter - the writer
+func NewGIFWriter(w io.Writer, every int, scale int, delay int) *GIFWriter {
+	return &GIFWriter{w: w, every: max(every, 1), scale: scale, delay: delay}
+}
+
+// Write adds a frame, whatever the chronon
+//
+// Parameters:
+//
+//	grid - the world to draw
+func (g *GIFWriter) Write(grid Grid) {
+	g.animation.Image = append(g.animation.Image, RenderPaletted(grid, g.scale))
+	g.animation.Delay = append(g.animation.Delay, g.delay)
+}
+
+// Observe adds a frame if the chronon the engine has just finished is one of the chronons being taken
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	nil
+func (g *GIFWriter) Observe(engine Engine) error {
+	if engine.Chronon()%g.every == 0 {
+		g.Write(engine)
+	}
+	return nil
+}
+
+// Close writes every frame collected so far as an animated GIF
+//
+// Returns:
+//
+//	error - if there are no frames or writing fails
+func (g *GIFWriter) Close() error {
+	return gif.EncodeAll(g.w, &g.animation)
+}
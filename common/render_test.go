@@ -0,0 +1,173 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	watorcommon "help/common"
+)
+
+// TestNewPNGWriterPatterns checks that NewPNGWriter only accepts file name patterns with exactly one integer verb
+func TestNewPNGWriterPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"wator-%d.png", true},
+		{"frames/wator-%06d.png", true},
+		{"wator-%x.png", true},
+		{"100%%-%-4d.png", true},
+		{"wator.png", false},
+		{"wator-%d-%d.png", false},
+		{"wator-%s.png", false},
+		{"wator-%v.png", false},
+		{"wator-%[1]d.png", false},
+		{"wator-%*d.png", false},
+		{"wator-%", false},
+		{"wator-%06", false},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			_, err := watorcommon.NewPNGWriter(test.pattern, 1, 1)
+			if test.valid && err != nil {
+				t.Errorf("got %v, want nil", err)
+			}
+			if !test.valid && !errors.Is(err, watorcommon.ErrFramePattern) {
+				t.Errorf("got %v, want %v", err, watorcommon.ErrFramePattern)
+			}
+		})
+	}
+}
+
+// TestPNGWriterNamesFrames checks that each frame is saved under the chronon it shows
+func TestPNGWriterNamesFrames(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := watorcommon.NewPNGWriter(filepath.Join(dir, "wator-%04d.png"), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid := cellGrid{width: 2, height: 2, cells: make([]watorcommon.Cell, 4)}
+	if err := writer.Write(grid, 12); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wator-0012.png")); err != nil {
+		t.Error(err)
+	}
+}
+
+// smallGrid is a 3x2 grid with a fish at (1, 0), a shark at (2, 1) and water everywhere else
+func smallGrid() cellGrid {
+	grid := cellGrid{width: 3, height: 2, cells: make([]watorcommon.Cell, 6)}
+	grid.cells[1*2+0] = watorcommon.Cell{TypeId: 1, BreedTimer: 5}
+	grid.cells[2*2+1] = watorcommon.Cell{TypeId: 2, Energy: 4, BreedTimer: 10}
+	return grid
+}
+
+// TestPNGFrame checks the size of a saved frame and the colour of a square of each kind, and that loading the
+// frame as a starting ocean gives back the same squares
+func TestPNGFrame(t *testing.T) {
+	grid := smallGrid()
+	for _, scale := range []int{1, 2} {
+		path := filepath.Join(t.TempDir(), "wator-%d.png")
+		writer, err := watorcommon.NewPNGWriter(path, 1, scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Write(grid, 0); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(filepath.Join(filepath.Dir(path), "wator-0.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		img, err := png.Decode(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := img.Bounds().Size(); size.X != 3*scale || size.Y != 2*scale {
+			t.Fatalf("scale %d: frame is %v, want %dx%d", scale, size, 3*scale, 2*scale)
+		}
+		for _, square := range []struct {
+			x, y   int
+			colour color.RGBA
+		}{{0, 0, watorcommon.Blue}, {1, 0, watorcommon.Yellow}, {2, 1, watorcommon.Red}} {
+			// The last pixel of the block, so a block drawn one pixel too small is caught
+			x, y := square.x*scale+scale-1, square.y*scale+scale-1
+			if got := color.RGBAModel.Convert(img.At(x, y)); got != square.colour {
+				t.Errorf("scale %d: square (%d, %d) is %v, want %v", scale, square.x, square.y, got, square.colour)
+			}
+		}
+		if scale != 1 {
+			continue
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		config := testConfig()
+		config.Threads, config.Scheduler = 1, watorcommon.SchedulerLocked
+		loaded, err := watorcommon.LoadImage(file, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, cell := range loaded.Cells {
+			if cell.TypeId != grid.cells[i].TypeId {
+				t.Errorf("square %d loaded as type %d, want %d", i, cell.TypeId, grid.cells[i].TypeId)
+			}
+		}
+	}
+}
+
+// TestGIFAnimation checks that every frame taken is in the animation, at the right size, delay and colours
+func TestGIFAnimation(t *testing.T) {
+	var out bytes.Buffer
+	writer := watorcommon.NewGIFWriter(&out, 1, 2, 7)
+	writer.Write(smallGrid())
+	writer.Write(cellGrid{width: 3, height: 2, cells: make([]watorcommon.Cell, 6)})
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != 2 {
+		t.Fatalf("%d frames, want 2", len(animation.Image))
+	}
+	for i, frame := range animation.Image {
+		if size := frame.Bounds().Size(); size.X != 6 || size.Y != 4 {
+			t.Errorf("frame %d is %v, want 6x4", i, size)
+		}
+		if animation.Delay[i] != 7 {
+			t.Errorf("frame %d has delay %d, want 7", i, animation.Delay[i])
+		}
+	}
+	if got := color.RGBAModel.Convert(animation.Image[0].At(5, 3)); got != watorcommon.Red {
+		t.Errorf("the shark is %v, want %v", got, watorcommon.Red)
+	}
+	if got := color.RGBAModel.Convert(animation.Image[1].At(5, 3)); got != watorcommon.Blue {
+		t.Errorf("the empty ocean is %v, want %v", got, watorcommon.Blue)
+	}
+}
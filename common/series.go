@@ -0,0 +1,188 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// seriesColumns are the CSV header names, matching the JSON names of the Stats fields
+var seriesColumns = []string{
+	"chronon", "fish", "sharks", "fish_births", "shark_births", "predations", "starvations", "blocked_moves",
+	"imbalance", "static_imbalance",
+}
+
+// SeriesWriter streams the Stats of every chronon to a file as the run progresses, one record per chronon, so
+// population dynamics can be plotted straight from it. Every SeriesWriter is also an Observer, so it can be
+// passed to RunHeadless
+type SeriesWriter interface {
+	Observer
+	Write(stats Stats) error
+}
+
+// NewSeriesWriter returns a SeriesWriter for the named format
+//
+// Parameters:
+//
+//	w - where the records are written
+//	format - "csv" or "jsonl"
+//
+// Returns:
+//
+//	SeriesWriter - the writer
+//	error - if the format is not recognised
+func NewSeriesWriter(w io.Writer, format string) (SeriesWriter, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return NewCSVWriter(w), nil
+	case "jsonl", "ndjson":
+		return NewJSONLWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown time series format %q, want csv or jsonl", format)
+}
+
+// SeriesFormat guesses the time series format from a file name's extension
+//
+// Parameters:
+//
+//	path - the file name
+//
+// Returns:
+//
+//	string - "jsonl" for .jsonl and .ndjson files, "csv" for anything else
+func SeriesFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+	return "csv"
+}
+
+// CSVWriter writes one comma separated row per chronon, after a header row
+type CSVWriter struct {
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter returns a CSVWriter that writes to w
+//
+// Parameters:
+//
+//	w - where the rows are written
+//
+// Returns:
+//
+//	*CSVWriter - the writer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{csv: csv.NewWriter(w)}
+}
+
+// Write writes the row for one chronon, preceded by the header row the first time. Every row is flushed as soon
+// as it is written so the file can be followed while the run is going
+//
+// Parameters:
+//
+//	stats - the chronon to write
+//
+// Returns:
+//
+//	error - if writing fails
+func (c *CSVWriter) Write(stats Stats) error {
+	if !c.headerWritten {
+		if err := c.csv.Write(seriesColumns); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+	values := []int{
+		stats.Chronon, stats.Fish, stats.Sharks, stats.FishBirths, stats.SharkBirths,
+		stats.Predations, stats.Starvations, stats.BlockedMoves,
+	}
+	record := make([]string, len(values), len(seriesColumns))
+	for i, value := range values {
+		record[i] = strconv.Itoa(value)
+	}
+	record = append(record, strconv.FormatFloat(stats.Imbalance, 'f', 3, 64),
+		strconv.FormatFloat(stats.StaticImbalance, 'f', 3, 64))
+	if err := c.csv.Write(record); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// Observe writes the stats of the chronon the engine has just finished
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	error - if writing fails
+func (c *CSVWriter) Observe(engine Engine) error {
+	return c.Write(engine.Stats())
+}
+
+// JSONLWriter writes one JSON object per line per chronon
+type JSONLWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter returns a JSONLWriter that writes to w
+//
+// Parameters:
+//
+//	w - where the lines are written
+//
+// Returns:
+//
+//	*JSONLWriter - the writer
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write writes the line for one chronon
+//
+// Parameters:
+//
+//	stats - the chronon to write
+//
+// Returns:
+//
+//	error - if writing fails
+func (j *JSONLWriter) Write(stats Stats) error {
+	return j.encoder.Encode(stats)
+}
+
+// Observe writes the stats of the chronon the engine has just finished
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	error - if writing fails
+func (j *JSONLWriter) Observe(engine Engine) error {
+	return j.Write(engine.Stats())
+}
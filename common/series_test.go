@@ -0,0 +1,77 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon_test
+
+import (
+	"bytes"
+	"testing"
+
+	watorcommon "help/common"
+)
+
+// TestSeriesWriters writes two chronons in each format and compares the output with what plotting tools are
+// pointed at
+func TestSeriesWriters(t *testing.T) {
+	series := []watorcommon.Stats{
+		{Chronon: 1, Fish: 300, Sharks: 60, FishBirths: 12, SharkBirths: 3, Predations: 9, Starvations: 2,
+			BlockedMoves: 4, Imbalance: 1.25, StaticImbalance: 1.5},
+		{Chronon: 2, Fish: 298, Sharks: 61},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "chronon,fish,sharks,fish_births,shark_births,predations,starvations,blocked_moves,imbalance," +
+			"static_imbalance\n" +
+			"1,300,60,12,3,9,2,4,1.250,1.500\n" +
+			"2,298,61,0,0,0,0,0,0.000,0.000\n"},
+		{"jsonl", `{"chronon":1,"fish":300,"sharks":60,"fish_births":12,"shark_births":3,"predations":9,` +
+			`"starvations":2,"blocked_moves":4,"imbalance":1.25,"static_imbalance":1.5}` + "\n" +
+			`{"chronon":2,"fish":298,"sharks":61,"fish_births":0,"shark_births":0,"predations":0,` +
+			`"starvations":0,"blocked_moves":0,"imbalance":0,"static_imbalance":0}` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var out bytes.Buffer
+			writer, err := watorcommon.NewSeriesWriter(&out, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, stats := range series {
+				if err := writer.Write(stats); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := out.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// TestSeriesFormat checks the format picked for each file name, and that an unknown format is refused
+func TestSeriesFormat(t *testing.T) {
+	for path, want := range map[string]string{
+		"stats.csv": "csv", "stats.CSV": "csv", "stats.jsonl": "jsonl", "stats.ndjson": "jsonl", "stats": "csv",
+	} {
+		if got := watorcommon.SeriesFormat(path); got != want {
+			t.Errorf("SeriesFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if _, err := watorcommon.NewSeriesWriter(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("NewSeriesWriter accepted xml")
+	}
+}
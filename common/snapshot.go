@@ -0,0 +1,262 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SnapshotVersion is the version of the snapshot format Save writes. Load refuses any other version
+const SnapshotVersion = 1
+
+// MaxSnapshotCells is the largest grid Load accepts. A header asking for more is treated as corrupt rather than
+// allocated
+const MaxSnapshotCells = 1 << 26
+
+// snapshotMagic starts every snapshot file
+var snapshotMagic = [4]byte{'W', 'T', 'O', 'R'}
+
+// Bits of the byte that records which update scheme the world uses
+const (
+	schemeDeterministic byte = 1 << iota
+	schemeAsynchronous
+)
+
+// ErrNotSnapshot is returned by Load when the input does not start like a snapshot
+var ErrNotSnapshot = errors.New("not a Wa-tor snapshot")
+
+// ErrSnapshotVersion is returned by Load when the snapshot was written by an unknown version of the format
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// ErrCorruptSnapshot is returned by Load and the engines when a snapshot does not hold a usable world
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+// Cell is the engine independent form of a single square of the grid
+//
+// Fields:
+//
+//	TypeId		0 = empty space, 1 = fish, 2 = shark
+//	Energy		shark energy
+//	BreedTimer	defines how long a fish or shark must live before breeding
+type Cell struct {
+	TypeId     int
+	Energy     int
+	BreedTimer int
+}
+
+// Snapshot is everything needed to resume a world exactly where it was left
+//
+// Fields:
+//
+//	Config		the parameters the world was created with. Seed is the seed actually used, never 0
+//	Chronon		the number of chronons the world had completed
+//	RNG			the state of each of the world's random number sources, in the order the engine keeps them
+//	Cells		every square of the grid, column by column, so (x, y) is at index x*Config.Height + y
+type Snapshot struct {
+	Config  Config
+	Chronon int
+	RNG     [][]byte
+	Cells   []Cell
+}
+
+// Save writes a snapshot in the compact binary format: a magic number and version, the parameters, seed, update
+// scheme and chronon, the random number states, then one type byte per cell followed by its energy and breedTimer as
+// varints when the cell is not empty
+//
+// Parameters:
+//
+//	w - where the snapshot is written
+//	snapshot - the snapshot to write
+//
+// Returns:
+//
+//	error - if the snapshot is inconsistent or writing fails
+func Save(w io.Writer, snapshot *Snapshot) error {
+	if len(snapshot.Cells) != snapshot.Config.Width*snapshot.Config.Height {
+		return fmt.Errorf("%w: %d cells for a %dx%d grid", ErrCorruptSnapshot,
+			len(snapshot.Cells), snapshot.Config.Width, snapshot.Config.Height)
+	}
+	out := bufio.NewWriter(w)
+	buf := make([]byte, 0, 64)
+	buf = append(buf, snapshotMagic[:]...)
+	buf = binary.BigEndian.AppendUint16(buf, SnapshotVersion)
+	c := snapshot.Config
+	for _, value := range []int{
+		c.Width, c.Height, c.NumFish, c.NumShark, c.FishBreed, c.SharkBreed, c.Starve, c.EnergyGain, c.Threads,
+	} {
+		buf = binary.AppendVarint(buf, int64(value))
+	}
+	buf = binary.AppendUvarint(buf, c.Seed)
+	var scheme byte
+	if c.Deterministic {
+		scheme |= schemeDeterministic
+	}
+	if c.Asynchronous {
+		scheme |= schemeAsynchronous
+	}
+	buf = append(buf, scheme)
+	buf = binary.AppendVarint(buf, int64(snapshot.Chronon))
+	buf = binary.AppendUvarint(buf, uint64(len(snapshot.RNG)))
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+	for _, state := range snapshot.RNG {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(state)))
+		buf = append(buf, state...)
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+	}
+	for _, cell := range snapshot.Cells {
+		buf = append(buf[:0], byte(cell.TypeId))
+		if cell.TypeId != 0 {
+			buf = binary.AppendVarint(buf, int64(cell.Energy))
+			buf = binary.AppendVarint(buf, int64(cell.BreedTimer))
+		}
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Load reads a snapshot written by Save
+//
+// Parameters:
+//
+//	r - where the snapshot is read from
+//
+// Returns:
+//
+//	*Snapshot - the snapshot
+//	error - ErrNotSnapshot, ErrSnapshotVersion or ErrCorruptSnapshot if the input cannot be used, or a read error
+func Load(r io.Reader) (*Snapshot, error) {
+	in := bufio.NewReader(r)
+	var header [6]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSnapshot, err)
+	}
+	if [4]byte(header[:4]) != snapshotMagic {
+		return nil, ErrNotSnapshot
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	snapshot := &Snapshot{}
+	c := &snapshot.Config
+	for _, field := range []*int{
+		&c.Width, &c.Height, &c.NumFish, &c.NumShark, &c.FishBreed, &c.SharkBreed, &c.Starve, &c.EnergyGain, &c.Threads,
+	} {
+		value, err := binary.ReadVarint(in)
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		*field = int(value)
+	}
+	seed, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	c.Seed = seed
+	scheme, err := in.ReadByte()
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	c.Deterministic = scheme&schemeDeterministic != 0
+	c.Asynchronous = scheme&schemeAsynchronous != 0
+	if err := c.Validate(); err != nil {
+		return nil, corrupt(err)
+	}
+	if c.Width > MaxSnapshotCells || c.Height > MaxSnapshotCells || c.Width*c.Height > MaxSnapshotCells {
+		return nil, corrupt(fmt.Errorf("%dx%d grid is larger than %d cells", c.Width, c.Height, MaxSnapshotCells))
+	}
+	chronon, err := binary.ReadVarint(in)
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	snapshot.Chronon = int(chronon)
+	states, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	if states > 1<<16 {
+		return nil, corrupt(fmt.Errorf("%d random number states", states))
+	}
+	snapshot.RNG = make([][]byte, states)
+	for i := range snapshot.RNG {
+		size, err := binary.ReadUvarint(in)
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		if size > 1<<10 {
+			return nil, corrupt(fmt.Errorf("random number state of %d bytes", size))
+		}
+		snapshot.RNG[i] = make([]byte, size)
+		if _, err := io.ReadFull(in, snapshot.RNG[i]); err != nil {
+			return nil, corrupt(err)
+		}
+	}
+	cells := c.Width * c.Height
+	snapshot.Cells = make([]Cell, 0, min(cells, 1<<16))
+	for i := 0; i < cells; i++ {
+		typeId, err := in.ReadByte()
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		if typeId > 2 {
+			return nil, corrupt(fmt.Errorf("cell %d has type %d", i, typeId))
+		}
+		if typeId == 0 {
+			snapshot.Cells = append(snapshot.Cells, Cell{})
+			continue
+		}
+		energy, err := binary.ReadVarint(in)
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		breedTimer, err := binary.ReadVarint(in)
+		if err != nil {
+			return nil, corrupt(err)
+		}
+		if energy < math.MinInt32 || energy > math.MaxInt32 || breedTimer < math.MinInt16 || breedTimer > math.MaxInt16 {
+			return nil, corrupt(fmt.Errorf("cell %d does not fit in a square", i))
+		}
+		snapshot.Cells = append(snapshot.Cells, Cell{TypeId: int(typeId), Energy: int(energy), BreedTimer: int(breedTimer)})
+	}
+	return snapshot, nil
+}
+
+// corrupt wraps a read or validation error as ErrCorruptSnapshot, turning a premature end of input into
+// io.ErrUnexpectedEOF
+//
+// Parameters:
+//
+//	err - what went wrong
+//
+// Returns:
+//
+//	error - the wrapped error
+func corrupt(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+}
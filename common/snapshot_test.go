@@ -0,0 +1,232 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	watorcommon "help/common"
+	watorconcurrent "help/concurrent"
+	watorsequential "help/sequential"
+)
+
+// snapshotter is what the round trip needs from either engine
+type snapshotter interface {
+	watorcommon.Engine
+	Snapshot() *watorcommon.Snapshot
+	Close() error
+}
+
+// engines creates and resumes worlds with each engine
+var engines = []struct {
+	name   string
+	create func(config watorcommon.Config) (snapshotter, error)
+	resume func(snapshot *watorcommon.Snapshot) (snapshotter, error)
+}{
+	{
+		name:   "sequential",
+		create: func(c watorcommon.Config) (snapshotter, error) { return watorsequential.NewWorld(c) },
+		resume: func(s *watorcommon.Snapshot) (snapshotter, error) { return watorsequential.NewWorldFromSnapshot(s) },
+	},
+	{
+		name:   "concurrent",
+		create: func(c watorcommon.Config) (snapshotter, error) { return watorconcurrent.NewWorld(c) },
+		resume: func(s *watorcommon.Snapshot) (snapshotter, error) { return watorconcurrent.NewWorldFromSnapshot(s) },
+	},
+}
+
+// testConfig is a small world with both kinds of animal, on a scheduler that repeats for a seed
+func testConfig() watorcommon.Config {
+	return watorcommon.Config{
+		Width: 40, Height: 30, NumFish: 300, NumShark: 60, FishBreed: 5, SharkBreed: 10, Starve: 4, EnergyGain: 2,
+		Threads: 3, Seed: 11, Scheduler: watorcommon.SchedulerPhased,
+	}
+}
+
+// savedSnapshot runs a world for a few chronons and returns the bytes Save writes for it
+func savedSnapshot(t *testing.T) []byte {
+	t.Helper()
+	w, err := watorsequential.NewWorld(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := w.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := watorcommon.Save(&out, w.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// TestSnapshotRoundTrip saves a world from each engine, loads it back and resumes it in each engine, which must
+// give back the same cells and chronon, and the same random number states when the engine is the same
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, from := range engines {
+		for _, to := range engines {
+			t.Run(from.name+" to "+to.name, func(t *testing.T) {
+				w, err := from.create(testConfig())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer w.Close()
+				for i := 0; i < 25; i++ {
+					if err := w.Update(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				saved := w.Snapshot()
+				var out bytes.Buffer
+				if err := watorcommon.Save(&out, saved); err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := watorcommon.Load(&out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if loaded.Chronon != saved.Chronon || !reflect.DeepEqual(loaded.Cells, saved.Cells) ||
+					!reflect.DeepEqual(loaded.RNG, saved.RNG) {
+					t.Fatal("Load did not give back the snapshot Save wrote")
+				}
+				resumed, err := to.resume(loaded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resumed.Close()
+				again := resumed.Snapshot()
+				if again.Chronon != saved.Chronon {
+					t.Errorf("chronon %d, want %d", again.Chronon, saved.Chronon)
+				}
+				if !reflect.DeepEqual(again.Cells, saved.Cells) {
+					t.Error("resumed world has different cells")
+				}
+				if from.name == to.name && !reflect.DeepEqual(again.RNG, saved.RNG) {
+					t.Error("resumed world has different random number states")
+				}
+			})
+		}
+	}
+}
+
+// TestResumeAcrossGenerationWrap runs each engine past the chronon where the 8-bit generation tags wrap round, with
+// the invariants checked, alongside a world resumed from a snapshot taken before the wrap. The resumed world starts
+// its tags again from 0, so the two only stay the same if no stale square is ever read as live. In the starved
+// ocean every shark dies within a few chronons, leaving squares nothing writes to again until the wrap
+func TestResumeAcrossGenerationWrap(t *testing.T) {
+	oceans := []struct {
+		name   string
+		fish   int
+		sharks int
+		alive  bool
+	}{
+		{"busy", 1700, 350, true},
+		{"starved", 0, 350, false},
+	}
+	for _, engine := range engines {
+		for _, ocean := range oceans {
+			for _, asynchronous := range []bool{false, true} {
+				name := engine.name + " " + ocean.name
+				if asynchronous {
+					name += " asynchronous"
+				}
+				t.Run(name, func(t *testing.T) {
+					// A bigger ocean than testConfig, where the busy one keeps both kinds alive in either scheme
+					config := testConfig()
+					config.Width, config.Height, config.NumFish, config.NumShark = 96, 72, ocean.fish, ocean.sharks
+					config.Asynchronous = asynchronous
+					config.Check = true
+					w, err := engine.create(config)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer w.Close()
+					for i := 0; i < 200; i++ {
+						if err := w.Update(); err != nil {
+							t.Fatalf("chronon %d: %v", i+1, err)
+						}
+					}
+					resumed, err := engine.resume(w.Snapshot())
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer resumed.Close()
+					for chronon := 201; chronon <= 320; chronon++ {
+						if err := w.Update(); err != nil {
+							t.Fatalf("chronon %d: %v", chronon, err)
+						}
+						if err := resumed.Update(); err != nil {
+							t.Fatalf("resumed chronon %d: %v", chronon, err)
+						}
+						if !reflect.DeepEqual(w.Snapshot().Cells, resumed.Snapshot().Cells) {
+							t.Fatalf("chronon %d: the resumed world has different cells", chronon)
+						}
+					}
+					if stats := w.Stats(); (stats.Fish > 0 && stats.Sharks > 0) != ocean.alive {
+						t.Fatalf("%d fish and %d sharks left, want both alive %v", stats.Fish, stats.Sharks, ocean.alive)
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestLoadErrors checks that input Load cannot use is reported with the right error instead of panicking
+func TestLoadErrors(t *testing.T) {
+	saved := savedSnapshot(t)
+	// header is a complete header with no random number states, so only the cells are missing
+	header := func(width int64, height int64) []byte {
+		b := []byte("WTOR")
+		b = binary.BigEndian.AppendUint16(b, watorcommon.SnapshotVersion)
+		for _, value := range []int64{width, height, 1, 1, 5, 10, 4, 2, 1} {
+			b = binary.AppendVarint(b, value)
+		}
+		b = binary.AppendUvarint(b, 1)
+		b = append(b, 0)
+		b = binary.AppendVarint(b, 0)
+		return binary.AppendUvarint(b, 0)
+	}
+	wrongVersion := bytes.Clone(saved)
+	binary.BigEndian.PutUint16(wrongVersion[4:], watorcommon.SnapshotVersion+1)
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{"empty", nil, watorcommon.ErrNotSnapshot},
+		{"bad magic", append([]byte("WTOX"), saved[4:]...), watorcommon.ErrNotSnapshot},
+		{"wrong version", wrongVersion, watorcommon.ErrSnapshotVersion},
+		{"truncated header", saved[:10], watorcommon.ErrCorruptSnapshot},
+		{"truncated cells", saved[:len(saved)-5], watorcommon.ErrCorruptSnapshot},
+		{"no cells", header(40, 30), watorcommon.ErrCorruptSnapshot},
+		{"oversized grid", header(1<<31, 1<<31), watorcommon.ErrCorruptSnapshot},
+		{"grid past the cap", header(100000, 100000), watorcommon.ErrCorruptSnapshot},
+		{"negative size", header(-1, 30), watorcommon.ErrCorruptSnapshot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := watorcommon.Load(bytes.NewReader(test.input)); !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorcommon
+
+// Stats records what happened during a single Update
+//
+// Fields:
+//
+//	Chronon			the chronon the stats describe, counting from 1
+//	Fish			the number of fish alive at the end of the chronon
+//	Sharks			the number of sharks alive at the end of the chronon
+//	FishBirths		the number of fish born
+//	SharkBirths		the number of sharks born
+//	Predations		the number of fish eaten by sharks
+//	Starvations		the number of sharks that ran out of energy
+//	BlockedMoves	the number of lost moves: animals that picked a square but could not move onto it because
+//					another animal got there first this chronon
+//	Imbalance		how unevenly the animals to move were shared out between the concurrent engine's workers: the
+//					most any worker moved divided by the average. 1 is perfectly even, 0 when it is not measured
+//	StaticImbalance	the Imbalance the same chronon would have had with every block on a fixed worker, to compare
+//					against Imbalance when Config.Balance lets workers take each other's blocks
+type Stats struct {
+	Chronon      int `json:"chronon"`
+	Fish         int `json:"fish"`
+	Sharks       int `json:"sharks"`
+	FishBirths   int `json:"fish_births"`
+	SharkBirths  int `json:"shark_births"`
+	Predations   int `json:"predations"`
+	Starvations  int `json:"starvations"`
+	BlockedMoves int `json:"blocked_moves"`
+
+	Imbalance       float64 `json:"imbalance"`
+	StaticImbalance float64 `json:"static_imbalance"`
+}
+
+// Add merges the counts of another set of stats into these ones, so that stats gathered separately by each
+// worker can be combined once the chronon is over. Chronon and the imbalances are left alone
+//
+// Parameters:
+//
+//	other - the stats to add
+func (s *Stats) Add(other Stats) {
+	s.Fish += other.Fish
+	s.Sharks += other.Sharks
+	s.FishBirths += other.FishBirths
+	s.SharkBirths += other.SharkBirths
+	s.Predations += other.Predations
+	s.Starvations += other.Starvations
+	s.BlockedMoves += other.BlockedMoves
+}
+
+// Imbalance measures how unevenly work was shared out
+//
+// Parameters:
+//
+//	loads - how much work each worker did
+//
+// Returns:
+//
+//	float64 - the largest load divided by the mean load, 0 if there was no work at all
+func Imbalance(loads []int) float64 {
+	most, total := 0, 0
+	for _, load := range loads {
+		most = max(most, load)
+		total += load
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(most) * float64(len(loads)) / float64(total)
+}
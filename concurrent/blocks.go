@@ -0,0 +1,232 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent
+
+import (
+	"sync"
+
+	watorcommon "help/common"
+)
+
+// blocksPerThread is how many blocks per thread the locked scheduler makes when it is balancing and the tile size
+// is automatic, so that a worker that finishes early still finds blocks left to take.
+const blocksPerThread = 4
+
+// block is one rectangle of the grid the locked scheduler sweeps as a unit.
+//
+// Fields:
+//
+//	startX	first column of the block.
+//	endX	one past the last column of the block.
+//	startY	first row of the block.
+//	endY	one past the last row of the block.
+type block struct {
+	startX int
+	endX   int
+	startY int
+	endY   int
+}
+
+// layoutBlocks splits the grid into blocks of the given size for the locked scheduler and gives each block a lock.
+// When both sizes are 0 it picks the number of blocks instead, with as many columns and rows of blocks as make them
+// closest to square, so that as few squares as possible are on a block's edge. If that many blocks do not fit it
+// falls back to one block per thread. Blocks are numbered column by column, the same way the grid is laid out.
+//
+// Parameters:
+//
+//	tileWidth - the width of a block, 0 for the width of the grid.
+//	tileHeight - the height of a block, 0 for the height of the grid.
+//	parts - the number of blocks to make when both sizes are 0.
+func (w *World) layoutBlocks(tileWidth int, tileHeight int, parts int) {
+	var columns, rows []int
+	if tileWidth == 0 && tileHeight == 0 {
+		across := 0
+		for c := parts; c >= 1; c-- {
+			if parts%c != 0 || c > w.width || parts/c > w.height {
+				continue
+			}
+			if across == 0 || w.width/c+w.height/(parts/c) < w.width/across+w.height/(parts/across) {
+				across = c
+			}
+		}
+		if across == 0 {
+			parts, across = w.threads, w.threads
+		}
+		columns = splitEvenly(w.width, across)
+		rows = splitEvenly(w.height, parts/across)
+	} else {
+		columns = splitBySize(w.width, tileWidth)
+		rows = splitBySize(w.height, tileHeight)
+	}
+	w.blockRows = len(rows) - 1
+	w.blocks = make([]block, 0, (len(columns)-1)*w.blockRows)
+	for c := 0; c < len(columns)-1; c++ {
+		for r := 0; r < w.blockRows; r++ {
+			w.blocks = append(w.blocks, block{startX: columns[c], endX: columns[c+1], startY: rows[r], endY: rows[r+1]})
+		}
+	}
+	w.blockColumnOf = spanIndex(columns)
+	w.blockRowOf = spanIndex(rows)
+	w.tileLocks = make([]sync.Mutex, len(w.blocks))
+	w.blockLoad = make([]int, len(w.blocks))
+}
+
+// splitEvenly cuts a length into parts of equal size, any remainder going to the last part.
+//
+// Parameters:
+//
+//	length - the length to cut up.
+//	parts - the number of parts.
+//
+// Returns:
+//
+//	[]int - where each part starts, followed by length.
+func splitEvenly(length int, parts int) []int {
+	starts := make([]int, parts+1)
+	for i := 0; i < parts; i++ {
+		starts[i] = i * (length / parts)
+	}
+	starts[parts] = length
+	return starts
+}
+
+// splitBySize cuts a length into parts of the given size, the last part taking whatever is left.
+//
+// Parameters:
+//
+//	length - the length to cut up.
+//	size - the size of each part, 0 or more than length for a single part.
+//
+// Returns:
+//
+//	[]int - where each part starts, followed by length.
+func splitBySize(length int, size int) []int {
+	if size <= 0 || size > length {
+		size = length
+	}
+	starts := []int{}
+	for position := 0; position < length; position += size {
+		starts = append(starts, position)
+	}
+	return append(starts, length)
+}
+
+// spanIndex turns a list of starts into a lookup table, so finding which part a coordinate is in takes one step.
+//
+// Parameters:
+//
+//	starts - where each part starts, followed by the total length.
+//
+// Returns:
+//
+//	[]int - the part each coordinate from 0 to the total length is in.
+func spanIndex(starts []int) []int {
+	index := make([]int, starts[len(starts)-1])
+	for part := 0; part < len(starts)-1; part++ {
+		for i := starts[part]; i < starts[part+1]; i++ {
+			index[i] = part
+		}
+	}
+	return index
+}
+
+// blockOf returns which block a square is in.
+//
+// Parameters:
+//
+//	x - x coordinate of the square.
+//	y - y coordinate of the square.
+//
+// Returns:
+//
+//	int - index of the block in blocks.
+func (w *World) blockOf(x int, y int) int {
+	return w.blockColumnOf[x]*w.blockRows + w.blockRowOf[y]
+}
+
+// onBlockEdge reports whether a square is in the first or last column or row of its block, where animals from the
+// neighbouring blocks can also move to.
+//
+// Parameters:
+//
+//	x - x coordinate of the square.
+//	y - y coordinate of the square.
+//	b - the block the square is in.
+//
+// Returns:
+//
+//	bool - true if the square can be written from another block.
+func onBlockEdge(x int, y int, b block) bool {
+	return x == b.startX || x == b.endX-1 || y == b.startY || y == b.endY-1
+}
+
+// blockSweep moves every animal of one kind in a worker's blocks using the locked scheduler. Normally worker i
+// sweeps blocks i, i+threads, i+2*threads and so on, all workers running at once. When balancing, every worker
+// instead takes the next block nobody has taken yet until there are none left, so the workers with little to do
+// end up sweeping more blocks. Either way, moves onto a block's edge go to whichever worker gets the lock first, so
+// the run is not repeatable for a seed.
+//
+// Parameters:
+//
+//	worker - the worker doing the sweep.
+//	typeId - the animals to move, 2 for sharks and 1 for fish.
+func (w *World) blockSweep(worker int, typeId int) {
+	if w.balance {
+		next := &w.nextBlock[typeId-1]
+		for i := int(next.Add(1)) - 1; i < len(w.blocks); i = int(next.Add(1)) - 1 {
+			w.sweepBlock(worker, i, typeId)
+		}
+		return
+	}
+	for i := worker; i < len(w.blocks); i += w.threads {
+		w.sweepBlock(worker, i, typeId)
+	}
+}
+
+// sweepBlock moves every animal of one kind in one block, counting them against both the block and the worker.
+//
+// Parameters:
+//
+//	worker - the worker doing the sweep.
+//	i - index of the block in blocks.
+//	typeId - the animals to move, 2 for sharks and 1 for fish.
+func (w *World) sweepBlock(worker int, i int, typeId int) {
+	b := w.blocks[i]
+	w.workers[worker].block = i
+	moved := w.sweep(b.startX, b.endX, b.startY, b.endY, worker, w.starts, typeId)
+	w.blockLoad[i] += moved
+	w.workers[worker].load += moved
+}
+
+// measureImbalance records in the stats how evenly the animals moved this chronon were shared between the workers,
+// and how evenly they would have been shared had every block stayed with a fixed worker. Without balancing the two
+// are the same.
+func (w *World) measureImbalance() {
+	loads := make([]int, w.threads)
+	for i := range w.workers {
+		loads[i] = w.workers[i].load
+	}
+	w.stats.Imbalance = watorcommon.Imbalance(loads)
+	w.stats.StaticImbalance = w.stats.Imbalance
+	if !w.balance || w.scheduler != watorcommon.SchedulerLocked {
+		return
+	}
+	clear(loads)
+	for i, load := range w.blockLoad {
+		loads[i%w.threads] += load
+	}
+	w.stats.StaticImbalance = watorcommon.Imbalance(loads)
+}
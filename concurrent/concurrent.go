@@ -19,81 +19,326 @@
 package watorconcurrent
 
 import (
-	"image/color"
+	"fmt"
 	"log"
 	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	watorcommon "help/common"
+
 	"github.com/hajimehoshi/ebiten"
 )
 
 // scale defines the drawing scale for each cell.
-const scale = 1
-
-// width and height define the size of the simulation grid.
-const width = 1800
-const height = 1000
-
-var blue color.Color = color.RGBA{69, 145, 196, 255}
-var yellow color.Color = color.RGBA{255, 230, 120, 255}
-var red color.Color = color.RGBA{255, 50, 50, 255}
-
-// buffer is a temporary grid used for writing the updated state of the world.
-var buffer [width][height]square = [width][height]square{}
-var count int = 0
-
-// numShark is the number of sharks the simultaion starts with.
-var numShark int = 100
-
-// numFish is the number of fish the simultaion starts with.
-var numFish int = 200000
-
-// fishBreed is the number of simulation steps it takes for a fish to reproduce.
-var fishBreed int = 5
+const scale = watorcommon.Scale
 
-// sharkBreed is the number of simultion steps it takes for a shark to reproduce.
-var sharkBreed int = 10
-
-// starve is the number of simulation steps it takes for a shark to starve.
-var starve int = 4
-
-// energyGain is how much energy a shark gains after eating a fish.
-var energyGain = 2
-
-// grid represents the current state of the world
-var grid [width][height]square = [width][height]square{}
-
-// threads represents the number of threads the simulation runs on when running concurrently.
-var threads int = 8
+// square represents a cell in the simulation grid, packed into 8 bytes so a cache line holds eight of them.
+//
+// Fields:
+//
+//	typeId		0 = empty space, 1 = fish, 2 = shark
+//	generation	the generation the square was written in, a square from an older one reads as empty.
+//	breedTimer	defines how long a fish or shark must live before breeding
+//	energy		shark energy
+type square struct {
+	typeId     uint8
+	generation uint8
+	breedTimer int16
+	energy     int32
+}
 
-// tileWidth represents the width of a tile ie. how many columns each tile contains.
-var tileWidth = width / threads
+// World can be driven by watorcommon.RunHeadless as well as by Frame.
+var _ watorcommon.Engine = (*World)(nil)
 
-// tileLocks is a slice of mutexes for each thread.
-var tileLocks = make([]sync.Mutex, threads)
+// newGrid allocates a width x height grid of empty squares. Every column is a slice of one shared backing array
+// so the whole grid stays contiguous in memory.
+//
+// Parameters:
+//
+//	width - number of columns.
+//	height - number of rows.
+//
+// Returns:
+//
+//	[][]square - the grid, indexed as grid[x][y].
+func newGrid(width int, height int) [][]square {
+	cells := make([]square, width*height)
+	grid := make([][]square, width)
+	for x := range grid {
+		grid[x] = cells[x*height : (x+1)*height]
+	}
+	return grid
+}
 
-// starts is a slice of ints representing the x values of where each tile starts.
-var starts = GetTileStarts(threads)
+// worker holds the state that belongs to a single thread, so threads never share it.
+//
+// Fields:
+//
+//	source	the random number source behind rng, kept so its state can be saved in a snapshot.
+//	rng		the random number generator every random choice on this worker's tile is made with.
+//	stats	what happened on this worker's tile during the current Update, merged into the World's stats after.
+//	halo	moves out of the left and right edge of the tile, only used by the halo scheduler, see haloSweep.
+//	block	the block this worker is sweeping, only used by the locked scheduler, see blockSweep.
+//	load	the number of animals this worker moved during the current Update.
+type worker struct {
+	source *rand.PCG
+	rng    *rand.Rand
+	stats  watorcommon.Stats
+	halo   [2][]crossing
+	block  int
+	load   int
+	_      [64]byte // keeps neighbouring workers' stats off the same cache line
+}
 
-// square represents a cell in the simulation grid.
+// World holds everything one concurrent simulation needs, so several worlds can run side by side.
 //
 // Fields:
 //
-//	typeId		0 = empty space, 1 = fish, 2 = shark
-//	energy		shark energy
-//	breedTimer	defines how long a fish or shark must live before breeding
-type square struct {
-	typeId     int
-	energy     int
-	breedTimer int
+//	width		the number of columns in the simulation grid.
+//	height		the number of rows in the simulation grid.
+//	grid		represents the current state of the world.
+//	buffer		a temporary grid used for writing the updated state of the world.
+//	generation	the tag a square in grid must carry to count, a square in buffer must carry one more.
+//	numShark	the number of sharks the simultaion starts with.
+//	numFish		the number of fish the simultaion starts with.
+//	fishBreed	the number of simulation steps it takes for a fish to reproduce.
+//	sharkBreed	the number of simultion steps it takes for a shark to reproduce.
+//	starve		the number of simulation steps it takes for a shark to starve.
+//	energyGain	how much energy a shark gains after eating a fish.
+//	threads		the number of threads the simulation runs on when running concurrently.
+//	tileLocks	a slice of mutexes for each block.
+//	starts		a slice of ints representing the x values of where each tile starts.
+//	scheduler	how the tiles are run, see watorcommon.Scheduler.
+//	tileWidth	the block width asked for in the config, 0 for automatic.
+//	tileHeight	the block height asked for in the config, 0 for automatic.
+//	blocks		the blocks the grid is split into, only used by the locked scheduler.
+//	blockRows	the number of blocks in each column of blocks.
+//	blockColumnOf	which column of blocks each x value is in.
+//	blockRowOf	which row of blocks each y value is in.
+//	balance		whether workers take the next free block instead of a fixed share, see blockSweep.
+//	nextBlock	the next block to hand out in the fish and the shark phase, only used when balancing.
+//	blockLoad	the number of animals moved in each block during the current Update.
+//	strips		the x values of where each strip starts, two strips per thread, only used by the phased scheduler.
+//	seed		the seed every random choice in this world derives from.
+//	workers		per thread state, each worker has its own random number stream.
+//	pool		the goroutines the workers run on, started with the world and stopped by Close.
+//	barrier		what the workers wait at between the phases of a chronon.
+//	chronon		the number of simulation steps completed so far.
+//	stats		what happened during the most recent Update.
+//	start		used for tracking elapsed time for measuring performance.
+//	pixels		the RGBA bytes Display uploads to the window, allocated by the first Display.
+//	deterministic	whether Update uses DeterministicUpdate.
+//	asynchronous	whether Update uses AsynchronousUpdate.
+//	order		the random order animals act in this chronon, only used by AsynchronousUpdate.
+//	acted		which squares hold an animal that has acted this chronon, only used by AsynchronousUpdate.
+//	check		whether Update finishes with watorcommon.CheckInvariants.
+//	direction	the move each animal proposed this chronon, only used by DeterministicUpdate.
+//	winner		which neighbour won each contested square this chronon, only used by DeterministicUpdate.
+//	dense		never turn sparse, see track.
+//	sparse		whether the sweeps visit only the squares on live instead of scanning their whole tile, see track.
+//	live		the squares holding an animal, numbered x*height+y and sorted, only kept while sparse.
+//	candidates	the squares census looks at, kept to reuse its memory.
+type World struct {
+	width      int
+	height     int
+	grid       [][]square
+	buffer     [][]square
+	generation uint8
+	count      int
+	numShark   int
+	numFish    int
+	fishBreed  int16
+	sharkBreed int16
+	starve     int32
+	energyGain int32
+	threads    int
+	tileLocks  []sync.Mutex
+	starts     []int
+	scheduler  watorcommon.Scheduler
+	tileWidth  int
+	tileHeight int
+	blocks     []block
+	blockRows  int
+	strips     []int
+	seed       uint64
+	workers    []worker
+	pool       *pool
+	barrier    *barrier
+	chronon    int
+	stats      watorcommon.Stats
+	start      time.Time
+	pixels     []byte
+
+	blockColumnOf []int
+	blockRowOf    []int
+	balance       bool
+	nextBlock     [2]atomic.Int64
+	blockLoad     []int
+	deterministic bool
+	asynchronous  bool
+	order         [][2]int
+	acted         []bool
+	check         bool
+	direction     [][]uint8
+	winner        [][]uint8
+	dense         bool
+	sparse        bool
+	live          []int
+	candidates    []int
+}
+
+// NewWorld creates a world from the given parameters, splits it into one tile per thread and scatters the
+// starting fish and sharks across it at random.
+//
+// Parameters:
+//
+//	config - the simulation parameters.
+//
+// Returns:
+//
+//	*World - the populated world.
+//	error - a *watorcommon.ConfigError for each invalid parameter, nil otherwise.
+func NewWorld(config watorcommon.Config) (*World, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	w := newWorld(config)
+	for i := range w.workers {
+		w.workers[i].source = watorcommon.NewSource(w.seed, uint64(i)+1)
+		w.workers[i].rng = rand.New(w.workers[i].source)
+	}
+	rng := rand.New(watorcommon.NewSource(w.seed, 0))
+	coords := [][2]int{}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			coords = append(coords, [2]int{x, y})
+		}
+	}
+	rng.Shuffle(len(coords), func(i, j int) {
+		coords[i], coords[j] = coords[j], coords[i]
+	})
+	for i := 0; i < w.numFish; i++ {
+		x := coords[i][0]
+		y := coords[i][1]
+		w.setGrid(x, y, square{typeId: 1, breedTimer: w.fishBreed})
+	}
+	for i := w.numFish; i < w.numShark+w.numFish; i++ {
+		x := coords[i][0]
+		y := coords[i][1]
+		w.setGrid(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed})
+	}
+	w.track(w.numFish + w.numShark)
+	w.pool = newPool(w.threads)
+	return w, nil
 }
 
-// chronon is used for tracking simulation steps.
-var chronon int = 0
+// NewWorldFromSnapshot recreates a world from a snapshot taken by either engine, split into snapshot.Config.Threads
+// tiles. The workers' random number states are restored when the snapshot holds one per thread, otherwise fresh
+// sources are derived from the seed and chronon so the resumed run is still repeatable.
+//
+// Parameters:
+//
+//	snapshot - the snapshot to resume from. Change snapshot.Config.Threads first to resume on a different number of threads.
+//
+// Returns:
+//
+//	*World - the world as it was when the snapshot was taken.
+//	error - a *watorcommon.ConfigError for each invalid parameter, or watorcommon.ErrCorruptSnapshot.
+func NewWorldFromSnapshot(snapshot *watorcommon.Snapshot) (*World, error) {
+	if err := snapshot.Config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(snapshot.Cells) != snapshot.Config.Width*snapshot.Config.Height {
+		return nil, fmt.Errorf("%w: %d cells for a %dx%d grid", watorcommon.ErrCorruptSnapshot,
+			len(snapshot.Cells), snapshot.Config.Width, snapshot.Config.Height)
+	}
+	w := newWorld(snapshot.Config)
+	w.chronon = snapshot.Chronon
+	for i := range w.workers {
+		if len(snapshot.RNG) == len(w.workers) {
+			w.workers[i].source = &rand.PCG{}
+			if err := w.workers[i].source.UnmarshalBinary(snapshot.RNG[i]); err != nil {
+				return nil, fmt.Errorf("%w: %v", watorcommon.ErrCorruptSnapshot, err)
+			}
+		} else {
+			w.workers[i].source = watorcommon.NewResumeSource(w.seed, w.chronon, uint64(i)+1)
+		}
+		w.workers[i].rng = rand.New(w.workers[i].source)
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			cell := snapshot.Cells[x*w.height+y]
+			w.setGrid(x, y, square{typeId: uint8(cell.TypeId), energy: int32(cell.Energy), breedTimer: int16(cell.BreedTimer)})
+		}
+	}
+	fish, sharks := w.Population()
+	w.track(fish + sharks)
+	w.pool = newPool(w.threads)
+	return w, nil
+}
 
-// start is used for tracking elapsed time for measuring performance.
-var start = time.Now()
+// newWorld allocates an empty world for parameters that have already been validated and splits it into one tile
+// per thread. The workers are left without random number sources.
+//
+// Parameters:
+//
+//	config - the simulation parameters.
+//
+// Returns:
+//
+//	*World - the world, with nothing in the grid.
+func newWorld(config watorcommon.Config) *World {
+	w := &World{
+		width:      config.Width,
+		height:     config.Height,
+		grid:       newGrid(config.Width, config.Height),
+		buffer:     newGrid(config.Width, config.Height),
+		numShark:   config.NumShark,
+		numFish:    config.NumFish,
+		fishBreed:  int16(config.FishBreed),
+		sharkBreed: int16(config.SharkBreed),
+		starve:     int32(config.Starve),
+		energyGain: int32(config.EnergyGain),
+		threads:    config.Threads,
+		seed:       watorcommon.PickSeed(config.Seed),
+		workers:    make([]worker, config.Threads),
+		barrier:    newBarrier(config.Threads),
+		start:      time.Now(),
+		scheduler:  config.Scheduler,
+		tileWidth:  config.TileWidth,
+		tileHeight: config.TileHeight,
+		balance:    config.Balance,
+		dense:      config.Dense,
+		check:      config.Check,
+	}
+	w.starts = w.GetTileStarts(w.threads)
+	switch w.scheduler {
+	case watorcommon.SchedulerLocked:
+		parts := w.threads
+		if w.balance {
+			parts *= blocksPerThread
+		}
+		w.layoutBlocks(w.tileWidth, w.tileHeight, parts)
+	case watorcommon.SchedulerPhased:
+		w.strips = w.GetTileStarts(2 * w.threads)
+	case watorcommon.SchedulerHalo:
+		for i := range w.workers {
+			w.workers[i].halo = [2][]crossing{make([]crossing, w.height), make([]crossing, w.height)}
+		}
+	}
+	if config.Deterministic {
+		w.deterministic = true
+		w.direction = newDirections(w.width, w.height)
+		w.winner = newDirections(w.width, w.height)
+	}
+	if config.Asynchronous {
+		w.asynchronous = true
+		w.acted = make([]bool, w.width*w.height)
+	}
+	return w
+}
 
 // Frame updates the simulation each Frame by calling the Update() function and the Display() function.
 //
@@ -103,24 +348,23 @@ var start = time.Now()
 //
 // Returns:
 //
-//	error - if the Update step fails. nil otherwise.
-func Frame(window *ebiten.Image) error {
-	count++
-	chronon++
+//	error - if the Update or Display step fails. nil otherwise.
+func (w *World) Frame(window *ebiten.Image) error {
+	w.count++
 	var err error = nil
 
-	if count == 1 {
-		err = Update()
-		count = 0
+	if w.count == 1 {
+		err = w.Update()
+		w.count = 0
 	}
-	if !ebiten.IsDrawingSkipped() {
-		Display(window)
+	if err == nil && !ebiten.IsDrawingSkipped() {
+		err = w.Display(window)
 	}
 
-	if chronon == 1000 {
-		var elapsed = time.Since(start)
+	if w.chronon%1000 == 0 {
+		var elapsed = time.Since(w.start)
 		log.Printf("Elapsed time for 1000 chronons : %s", elapsed)
-		chronon = 0
+		w.start = time.Now()
 	}
 
 	return err
@@ -137,22 +381,22 @@ func Frame(window *ebiten.Image) error {
 // Returns:
 //
 //	[][2]int - containing the coordinates of all free squares. if there are no free squares returns empty slice.
-func GatherFreeSquares(x int, y int) [][2]int {
+func (w *World) GatherFreeSquares(x int, y int) [][2]int {
 	freeSquares := [][2]int{}
-	leftX := (x - 1 + width) % width
-	rightX := (x + 1) % width
-	upY := (y - 1 + height) % height
-	downY := (y + 1) % height
-	if grid[x][upY].typeId == 0 {
+	leftX := (x - 1 + w.width) % w.width
+	rightX := (x + 1) % w.width
+	upY := (y - 1 + w.height) % w.height
+	downY := (y + 1) % w.height
+	if w.gridAt(x, upY).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{x, upY})
 	}
-	if grid[leftX][y].typeId == 0 {
+	if w.gridAt(leftX, y).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{leftX, y})
 	}
-	if grid[rightX][y].typeId == 0 {
+	if w.gridAt(rightX, y).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{rightX, y})
 	}
-	if grid[x][downY].typeId == 0 {
+	if w.gridAt(x, downY).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{x, downY})
 	}
 	return freeSquares
@@ -170,32 +414,34 @@ func GatherFreeSquares(x int, y int) [][2]int {
 // Returns:
 //
 //	[][2]int - containing the coordinates of all fish squares. if there are no fish squares returns empty slice.
-func GatherFishSquares(x int, y int) [][2]int {
+func (w *World) GatherFishSquares(x int, y int) [][2]int {
 	fishSquares := [][2]int{}
-	leftX := (x - 1 + width) % width
-	rightX := (x + 1) % width
-	upY := (y - 1 + height) % height
-	downY := (y + 1) % height
-	if grid[x][upY].typeId == 1 {
+	leftX := (x - 1 + w.width) % w.width
+	rightX := (x + 1) % w.width
+	upY := (y - 1 + w.height) % w.height
+	downY := (y + 1) % w.height
+	if w.gridAt(x, upY).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{x, upY})
 	}
-	if grid[leftX][y].typeId == 1 {
+	if w.gridAt(leftX, y).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{leftX, y})
 	}
-	if grid[rightX][y].typeId == 1 {
+	if w.gridAt(rightX, y).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{rightX, y})
 	}
-	if grid[x][downY].typeId == 1 {
+	if w.gridAt(x, downY).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{x, downY})
 	}
 	return fishSquares
 }
 
-// UpdateFish takes in the coordinates of a particular fish. It checks if this fish is still in the buffer.
-// If not, GatherFreeSquares is called. if there are free squares, one is picked at random and SafeWrite
+// UpdateFish takes in the coordinates of a particular fish. It runs in the second phase of Update, after every
+// shark has moved, so a fish whose square already holds a shark in the buffer has been eaten and is skipped.
+// Otherwise GatherFreeSquares is called. if there are free squares, one is picked at random and SafeWrite
 // attempts to write to the new coordinates. If SafeWrite fails or there are no adjacent cells free the fish
 // stays put. UpdateFish also handles breeding by checking the moved fishes' breedtimer. if it is <=0 a new fish is
-// placed in it's old place and both fishes' breedTimers are reset.
+// placed in it's old place and both fishes' breedTimers are reset. A fish that cannot move does not breed, its
+// breedTimer is reset instead.
 //
 // Parameters:
 //
@@ -207,138 +453,100 @@ func GatherFishSquares(x int, y int) [][2]int {
 // Returns:
 //
 //	nil
-func UpdateFish(x int, y int, worker int, starts []int) error {
-	currentSquare := grid[x][y]
-	if currentSquare.typeId != 1 {
+func (w *World) UpdateFish(x int, y int, worker int, starts []int) error {
+	currentSquare := w.gridAt(x, y)
+	if currentSquare.typeId != 1 || w.bufferAt(x, y).typeId == 2 {
 		return nil
 	}
-	freeSquares := GatherFreeSquares(x, y)
-	newX, newY := x, y
-	moved := false
+	next := square{typeId: 1, breedTimer: currentSquare.breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.fishBreed
+	}
+	freeSquares := w.GatherFreeSquares(x, y)
 	if len(freeSquares) > 0 {
-		newPosition := rand.IntN(len(freeSquares))
-		newX = freeSquares[newPosition][0]
-		newY = freeSquares[newPosition][1]
-		moved = SafeWrite(newX, newY, square{
-			typeId:     1,
-			breedTimer: currentSquare.breedTimer - 1,
-		}, worker, starts)
-		if !moved {
-			SafeWrite(x, y, square{
-				typeId:     1,
-				breedTimer: currentSquare.breedTimer - 1,
-			}, worker, starts)
-			newX = x
-			newY = y
+		newPosition := w.workers[worker].rng.IntN(len(freeSquares))
+		if w.SafeWrite(freeSquares[newPosition][0], freeSquares[newPosition][1], next, worker, starts) {
+			if breeds {
+				w.SafeWrite(x, y, square{typeId: 1, breedTimer: w.fishBreed}, worker, starts)
+				w.workers[worker].stats.FishBirths++
+			}
+			return nil
 		}
-	} else {
-		SafeWrite(x, y, square{
-			typeId:     1,
-			breedTimer: currentSquare.breedTimer - 1,
-		}, worker, starts)
-	}
-	if currentSquare.breedTimer <= 0 {
-		SafeWrite(x, y, square{
-			typeId:     1,
-			breedTimer: fishBreed,
-		}, worker, starts)
-		SafeWrite(newX, newY, square{
-			typeId:     1,
-			breedTimer: fishBreed,
-		}, worker, starts)
+		w.workers[worker].stats.BlockedMoves++
 	}
+	w.SafeWrite(x, y, next, worker, starts)
 	return nil
 }
 
-// UpdateSharks takes in the coordinates of a particular shark. GatherFishSquares and GatherFreeSquares is called.
-// if there are adjacent fish squares one is picked at random and SafeWrite attempts to write to the new coordinates.
-// If SafeWrite fails the shark will stay put. If there are no fish squares but there are free squares one is picked
-// at random and SafeWrite attempts to write to the new coordinates. If SafeWrite fails the shark will stay put.
-// A shark loses 1 energy per turn and gains a specified amount of energy upon eating a fish. If a sharks energy is
-// <=0 it disappears. when a sharks breedtimer is <=0 a new shark is placed at its old position and both sharks'
-// breedTimers reset.
+// UpdateSharks takes in the coordinates of a particular shark. It runs in the first phase of Update, before any
+// fish has moved. GatherFishSquares is called and if there are adjacent fish squares one is picked at random and
+// SafeWrite attempts to write the shark onto it. SafeWrite only succeeds for the first shark to reach the fish, and
+// that shark eats it: the fish's square now holds a shark in the buffer, so UpdateFish skips the fish in the second
+// phase and it is removed wherever it would have gone. Only that shark gains energy. If there are no fish squares,
+// or another shark got there first, a free square is picked at random instead, and if SafeWrite fails there too the
+// shark stays put. A shark loses 1 energy per turn and gains a specified amount of energy upon eating a fish. If a
+// sharks energy is <=0 it disappears, and a shark that would starve even after eating does not hunt. when a sharks
+// breedtimer is <=0 and it moved a new shark is placed at its old position and both sharks' breedTimers reset.
 //
 // Parameters:
 //
-//	x - x coordinate of current fish square.
-//	y - y coordinate of current fish square.
+//	x - x coordinate of current shark square.
+//	y - y coordinate of current shark square.
 //	worker int - current tile/thread we are working on.
 //	starts []int - represents x values of where each tile starts.
 //
 // Returns:
 //
 //	nil
-func UpdateSharks(x int, y int, worker int, starts []int) error {
-	currentSquare := grid[x][y]
+func (w *World) UpdateSharks(x int, y int, worker int, starts []int) error {
+	currentSquare := w.gridAt(x, y)
 	if currentSquare.typeId != 2 {
 		return nil
 	}
-	freeSquares := GatherFreeSquares(x, y)
-	fishSquares := GatherFishSquares(x, y)
-	newX, newY := x, y
-	energyAfterMove := currentSquare.energy - 1
+	stats := &w.workers[worker].stats
+	next := square{typeId: 2, energy: currentSquare.energy - 1, breedTimer: currentSquare.breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.sharkBreed
+	}
 	moved := false
-	if len(fishSquares) > 0 {
-		newPosition := rand.IntN(len(fishSquares))
-		newX = fishSquares[newPosition][0]
-		newY = fishSquares[newPosition][1]
-		moved = SafeWrite(newX, newY, square{
-			typeId:     2,
-			energy:     energyAfterMove + energyGain,
-			breedTimer: currentSquare.breedTimer - 1,
-		}, worker, starts)
-		if moved {
-			energyAfterMove += energyGain
-		} else {
-			SafeWrite(x, y, square{
-				typeId:     2,
-				energy:     energyAfterMove,
-				breedTimer: currentSquare.breedTimer - 1,
-			}, worker, starts)
+	if next.energy+w.energyGain > 0 {
+		fishSquares := w.GatherFishSquares(x, y)
+		if len(fishSquares) > 0 {
+			newPosition := w.workers[worker].rng.IntN(len(fishSquares))
+			fed := next
+			fed.energy += w.energyGain
+			if w.SafeWrite(fishSquares[newPosition][0], fishSquares[newPosition][1], fed, worker, starts) {
+				moved = true
+				stats.Predations++
+			} else {
+				stats.BlockedMoves++
+			}
 		}
-	} else if len(freeSquares) > 0 {
-		newPosition := rand.IntN(len(freeSquares))
-		newX = freeSquares[newPosition][0]
-		newY = freeSquares[newPosition][1]
-		moved = SafeWrite(newX, newY, square{
-			typeId:     2,
-			energy:     energyAfterMove,
-			breedTimer: currentSquare.breedTimer - 1,
-		}, worker, starts)
-		if !moved {
-			newX = x
-			newY = y
+	}
+	if !moved && next.energy <= 0 {
+		stats.Starvations++
+		return nil
+	}
+	if !moved {
+		freeSquares := w.GatherFreeSquares(x, y)
+		if len(freeSquares) > 0 {
+			newPosition := w.workers[worker].rng.IntN(len(freeSquares))
+			if w.SafeWrite(freeSquares[newPosition][0], freeSquares[newPosition][1], next, worker, starts) {
+				moved = true
+			} else {
+				stats.BlockedMoves++
+			}
 		}
-	} else {
-		newX = x
-		newY = y
 	}
 	if !moved {
-		SafeWrite(newX, newY, square{
-			typeId:     2,
-			energy:     energyAfterMove,
-			breedTimer: currentSquare.breedTimer - 1,
-		}, worker, starts)
-	}
-	if energyAfterMove <= 0 {
-		SafeWrite(newX, newY, square{
-			typeId:     0,
-			energy:     0,
-			breedTimer: 0,
-		}, worker, starts)
+		w.SafeWrite(x, y, next, worker, starts)
 		return nil
 	}
-	if currentSquare.breedTimer <= 0 {
-		SafeWrite(newX, newY, square{
-			typeId:     2,
-			energy:     energyAfterMove,
-			breedTimer: sharkBreed,
-		}, worker, starts)
-		SafeWrite(x, y, square{
-			typeId:     2,
-			energy:     starve,
-			breedTimer: sharkBreed,
-		}, worker, starts)
+	if breeds {
+		w.SafeWrite(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed}, worker, starts)
+		stats.SharkBirths++
 	}
 	return nil
 }
@@ -371,24 +579,17 @@ func TileOfX(x int, starts []int) int {
 // Returns:
 //
 //	[]int - slice of ints representing the x values of where each tile starts.
-func GetTileStarts(threads int) []int {
-	remainingWidth := width % threads
-	starts := make([]int, threads+1)
-	position := 0
-	for i := 0; i < threads; i++ {
-		starts[i] = position
-		add := tileWidth
-		if i == threads-1 {
-			add += remainingWidth
-		}
-		position += add
-	}
-	starts[threads] = position
-	return starts
+func (w *World) GetTileStarts(threads int) []int {
+	return splitEvenly(w.width, threads)
 }
 
-// SafeWrite checks whether or not the coordinates that are being written to are in the current tile (no lock will be needed)
-// or if it is in a different tile (a lock will be needed). It also checks the buffer to ensure only legal writes are allowed.
+// SafeWrite checks whether or not the coordinates that are being written to can be reached by another block. Animals
+// only move one square, so other blocks can only write to the first and last column and row of a block: writes
+// anywhere else in the block being swept need no lock, while writes to an edge square or to a different block take
+// that block's lock. A square is only written if it is still empty in the buffer, so an animal is never overwritten and the
+// first animal to reach a square gets it. The phased scheduler never runs two strips that can write to the same
+// column at once, so it takes no locks at all. The halo scheduler takes no locks either: a write to another tile is
+// held in the worker's halo and reported as successful, and haloSweep undoes the move later if it loses.
 //
 // Parameters:
 //
@@ -396,147 +597,371 @@ func GetTileStarts(threads int) []int {
 //	y int - y coordinate of new square.
 //	square square - details of the square we want to SafeWrite.
 //	workerTile int - current tile/thread we are working on.
-//	starts []int - slice representing x values of where each tile starts.
+//	starts []int - slice representing x values of where each tile starts, not used by the locked scheduler.
 //
 // Returns:
 //
 //	bool - returns whether or not the SafeWrite was successful.
-func SafeWrite(x int, y int, square square, workerTile int, starts []int) bool {
-	targetTile := TileOfX(x, starts)
-	if targetTile == workerTile {
-		existing := buffer[x][y]
-		if existing.typeId == 0 {
-			buffer[x][y] = square
-			return true
+func (w *World) SafeWrite(x int, y int, square square, workerTile int, starts []int) bool {
+	switch w.scheduler {
+	case watorcommon.SchedulerLocked:
+		target := w.blockOf(x, y)
+		if target != w.workers[workerTile].block || onBlockEdge(x, y, w.blocks[target]) {
+			w.tileLocks[target].Lock()
+			defer w.tileLocks[target].Unlock()
 		}
-		if existing.typeId == 1 && square.typeId == 2 {
-			buffer[x][y] = square
-			return true
-		}
-		if existing.typeId == 2 && square.typeId == 0 {
-			buffer[x][y] = square
+	case watorcommon.SchedulerHalo:
+		if TileOfX(x, starts) != workerTile {
+			w.send(x, y, square, workerTile)
 			return true
 		}
+	}
+	if w.bufferAt(x, y).typeId != 0 {
 		return false
 	}
+	w.setBuffer(x, y, square)
+	return true
+}
 
-	tileLocks[targetTile].Lock()
-	defer tileLocks[targetTile].Unlock()
-	existing := buffer[x][y]
-	if existing.typeId == 0 {
-		buffer[x][y] = square
-		return true
+// Update hands ConcurrentUpdate to every worker of the pool, which runs each chronon in two phases: every shark
+// moves, all workers wait at the barrier, then every fish that was not eaten moves. Once all workers have finished
+// it swaps grid with buffer. In deterministic mode DeterministicUpdate is called instead of ConcurrentUpdate, and in
+// asynchronous mode AsynchronousUpdate changes grid in place with no swap. The buffer is never cleared, the swap
+// moves every square still in it a generation out of date instead.
+// Each worker counts events and the new population of its own tile, and the counts are merged into Stats once every
+// worker is done, along with how evenly the work was shared. While the world is sparse every sweep visits only the
+// squares on its list of live animals, in the same order a scan would visit them so the result is the same either
+// way, and the population is counted from the list and its neighbours. DeterministicUpdate still visits every
+// square. With Config.Check set the invariants are then checked.
+//
+// Returns:
+//
+//	error - every invariant that is broken when checking is on, nil otherwise.
+func (w *World) Update() error {
+	var fishBefore, sharksBefore int
+	if w.check {
+		fishBefore, sharksBefore = w.Population()
+	}
+	for i := range w.workers {
+		w.workers[i].stats = watorcommon.Stats{}
+		w.workers[i].load = 0
+	}
+	w.nextBlock[0].Store(0)
+	w.nextBlock[1].Store(0)
+	clear(w.blockLoad)
+	switch {
+	case w.asynchronous:
+		w.AsynchronousUpdate()
+	case w.deterministic:
+		w.DeterministicUpdate()
+		w.swap()
+	default:
+		w.pool.run(w.ConcurrentUpdate)
+		w.swap()
+	}
+
+	if !w.sparse {
+		w.eachTile(func(worker int, startX int, endX int) {
+			for x := startX; x < endX; x++ {
+				for y := 0; y < w.height; y++ {
+					if w.gridAt(x, y).typeId == 1 {
+						w.workers[worker].stats.Fish++
+					} else if w.gridAt(x, y).typeId == 2 {
+						w.workers[worker].stats.Sharks++
+					}
+				}
+			}
+		})
 	}
-	if existing.typeId == 1 && square.typeId == 2 {
-		buffer[x][y] = square
-		return true
+	w.chronon++
+	w.stats = watorcommon.Stats{Chronon: w.chronon}
+	for i := range w.workers {
+		w.stats.Add(w.workers[i].stats)
 	}
-	if existing.typeId == 2 && square.typeId == 0 {
-		buffer[x][y] = square
-		return true
+	if w.sparse {
+		w.stats.Fish, w.stats.Sharks = w.census()
 	}
-	return false
+	w.measureImbalance()
+	w.track(w.stats.Fish + w.stats.Sharks)
+
+	if w.check {
+		return watorcommon.CheckInvariants(w, int(w.fishBreed), int(w.sharkBreed), fishBefore, sharksBefore, w.stats)
+	}
+	return nil
 }
 
-// Update splits tiles up based on number of threads, calls ConcurrentUpdate, waits for all routines to finish,
-// sets grid to be buffer, and zeros the buffer each Frame.
+// ConcurrentUpdate is one worker's share of a chronon. It moves the sharks and then the fish on the part of the
+// grid the scheduler gives this worker, with blockSweep, phasedSweep or haloSweep, and waits at the barrier for
+// every other worker after each phase so no fish moves before every shark has.
 //
-// Returns:
+// Parameters:
 //
-//	nil
-func Update() error {
-	var wg sync.WaitGroup
-	for worker := 0; worker < threads; worker++ {
-		startX := starts[worker]
-		endX := starts[worker+1]
-		if endX > width {
-			endX = width
+//	worker int - the worker, also its goroutine in the pool.
+func (w *World) ConcurrentUpdate(worker int) {
+	for _, typeId := range []int{2, 1} {
+		switch w.scheduler {
+		case watorcommon.SchedulerPhased:
+			w.phasedSweep(worker, typeId)
+		case watorcommon.SchedulerHalo:
+			w.haloSweep(worker, typeId)
+		default:
+			w.blockSweep(worker, typeId)
 		}
-		wg.Add(1)
-		go ConcurrentUpdate(&wg, startX, endX, worker, starts)
+		w.barrier.wait()
 	}
+}
 
-	wg.Wait()
-
-	grid = buffer
+// phasedSweep moves every animal of one kind in two phases. In the first phase each worker moves the animals in
+// its even strip, in the second those in its odd strip, with every worker finishing a phase before the next one
+// starts. Animals move at most one column, so strips running in the same phase are at least one strip apart and
+// never write to the same column. There is an even number of strips, so this holds across the wrap-around too.
+// Each worker also always handles the same strips in the same order, so for a given seed and number of threads
+// the run is reproducible. The caller waits at the barrier after the second phase.
+//
+// Parameters:
+//
+//	worker - the worker doing the sweep.
+//	typeId - the animals to move, 2 for sharks and 1 for fish.
+func (w *World) phasedSweep(worker int, typeId int) {
+	for phase := 0; phase < 2; phase++ {
+		if phase > 0 {
+			w.barrier.wait()
+		}
+		strip := 2*worker + phase
+		w.workers[worker].load += w.sweep(w.strips[strip], w.strips[strip+1], 0, w.height, worker, w.strips, typeId)
+	}
+}
 
-	buffer = [width][height]square{}
+// Seed returns the seed this world was created with, so that a run can be repeated exactly.
+//
+// Returns:
+//
+//	uint64 - the seed.
+func (w *World) Seed() uint64 {
+	return w.seed
+}
 
-	return nil
+// Stats returns what happened during the most recent Update.
+//
+// Returns:
+//
+//	watorcommon.Stats - the counts for the last chronon, all zero before the first Update.
+func (w *World) Stats() watorcommon.Stats {
+	return w.stats
 }
 
-// ConcurrentUpdate iterates through a specified tile in the grid and detects whether each cell
-// contains a fish or a shark and calls the relevant function.
+// Chronon returns how many simulation steps the world has taken.
 //
-// Parameters:
+// Returns:
 //
-//	wg *sync.WaitGroup - waitgroup for synchronization.
-//	startX int - tile start.
-//	endX int - tile end.
-//	worker int - current tile number.
-//	starts []int - slice representing x values of where each tile starts.
-func ConcurrentUpdate(wg *sync.WaitGroup, startX int, endX int, worker int, starts []int) {
-	defer wg.Done()
+//	int - the number of completed Update calls.
+func (w *World) Chronon() int {
+	return w.chronon
+}
 
-	for x := startX; x < endX; x++ {
-		for y := 0; y < height; y++ {
-			if grid[x][y].typeId == 1 {
-				UpdateFish(x, y, worker, starts)
-			} else if grid[x][y].typeId == 2 {
-				UpdateSharks(x, y, worker, starts)
+// Population counts the fish and sharks currently in the grid.
+//
+// Returns:
+//
+//	fish int - the number of fish.
+//	sharks int - the number of sharks.
+func (w *World) Population() (fish int, sharks int) {
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			if w.gridAt(x, y).typeId == 1 {
+				fish++
+			} else if w.gridAt(x, y).typeId == 2 {
+				sharks++
 			}
 		}
 	}
+	return fish, sharks
 }
 
-// Display draws the new grid after each Update loop.
+// sweep calls UpdateSharks or UpdateFish for every cell of a rectangle of the grid containing the kind of animal
+// being moved in this phase, and counts how many it moved.
 //
 // Parameters:
 //
-//	window — the Ebiten image buffer used for drawing.
-func Display(window *ebiten.Image) {
-	window.Fill(blue)
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			for i := 0; i < scale; i++ {
-				for j := 0; j < scale; j++ {
-					if grid[x][y].typeId == 1 {
-						window.Set(x*scale+i, y*scale+j, yellow)
-					} else if grid[x][y].typeId == 2 {
-						window.Set(x*scale+i, y*scale+j, red)
-					}
-				}
+//	startX int - first column.
+//	endX int - one past the last column.
+//	startY int - first row.
+//	endY int - one past the last row.
+//	worker int - the worker doing the sweep.
+//	starts []int - slice representing x values of where each tile starts.
+//	typeId int - the animals to move, 2 for sharks in the first phase and 1 for fish in the second.
+//
+// Returns:
+//
+//	int - the number of animals moved.
+func (w *World) sweep(startX int, endX int, startY int, endY int, worker int, starts []int, typeId int) int {
+	if w.sparse {
+		return w.sweepLive(startX, endX, startY, endY, worker, starts, typeId)
+	}
+	moved := 0
+	for x := startX; x < endX; x++ {
+		for y := startY; y < endY; y++ {
+			if int(w.gridAt(x, y).typeId) != typeId {
+				continue
+			}
+			moved++
+			if typeId == 1 {
+				w.UpdateFish(x, y, worker, starts)
+			} else {
+				w.UpdateSharks(x, y, worker, starts)
 			}
 		}
 	}
+	return moved
+}
 
+// eachTile runs fn for every tile at once, each on its worker's goroutine in the pool, and waits for all of them
+// to finish.
+//
+// Parameters:
+//
+//	fn - called with the worker number and the columns [startX, endX) of each tile.
+func (w *World) eachTile(fn func(worker int, startX int, endX int)) {
+	w.pool.run(func(worker int) {
+		fn(worker, w.starts[worker], w.starts[worker+1])
+	})
 }
 
-// RunConcurrent initializes the grid and starts the concurrent simulation loop.
-func RunConcurrent() {
-	coords := [][2]int{}
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			coords = append(coords, [2]int{x, y})
+// Close stops the world's worker goroutines. The world cannot be updated or displayed afterwards. Closing it again
+// does nothing.
+//
+// Returns:
+//
+//	error - always nil.
+func (w *World) Close() error {
+	w.pool.close()
+	return nil
+}
+
+// Size returns the dimensions of the grid.
+//
+// Returns:
+//
+//	width int - the number of columns.
+//	height int - the number of rows.
+func (w *World) Size() (width int, height int) {
+	return w.width, w.height
+}
+
+// Cell returns the square at (x, y).
+//
+// Parameters:
+//
+//	x - x coordinate of the square.
+//	y - y coordinate of the square.
+//
+// Returns:
+//
+//	watorcommon.Cell - what the square holds.
+func (w *World) Cell(x int, y int) watorcommon.Cell {
+	current := w.gridAt(x, y)
+	return watorcommon.Cell{TypeId: int(current.typeId), Energy: int(current.energy), BreedTimer: int(current.breedTimer)}
+}
+
+// Snapshot captures everything needed to resume this world later with NewWorldFromSnapshot, in either engine.
+//
+// Returns:
+//
+//	*watorcommon.Snapshot - the parameters, chronon, every worker's random number state and every cell of the grid.
+func (w *World) Snapshot() *watorcommon.Snapshot {
+	snapshot := &watorcommon.Snapshot{
+		Config: watorcommon.Config{
+			Width:         w.width,
+			Height:        w.height,
+			NumFish:       w.numFish,
+			NumShark:      w.numShark,
+			FishBreed:     int(w.fishBreed),
+			SharkBreed:    int(w.sharkBreed),
+			Starve:        int(w.starve),
+			EnergyGain:    int(w.energyGain),
+			Threads:       w.threads,
+			Seed:          w.seed,
+			Deterministic: w.deterministic,
+			Asynchronous:  w.asynchronous,
+			Scheduler:     w.scheduler,
+			TileWidth:     w.tileWidth,
+			TileHeight:    w.tileHeight,
+			Balance:       w.balance,
+			Dense:         w.dense,
+			Check:         w.check,
+		},
+		Chronon: w.chronon,
+		RNG:     make([][]byte, len(w.workers)),
+		Cells:   make([]watorcommon.Cell, w.width*w.height),
+	}
+	for i := range w.workers {
+		snapshot.RNG[i], _ = w.workers[i].source.MarshalBinary()
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			snapshot.Cells[x*w.height+y] = w.Cell(x, y)
 		}
 	}
-	rand.Shuffle(len(coords), func(i, j int) {
-		coords[i], coords[j] = coords[j], coords[i]
+	return snapshot
+}
+
+// Display draws the new grid after each Update loop. Every tile paints its own columns of the pixel buffer in
+// parallel, then the whole buffer is uploaded to the window in one call.
+//
+// Parameters:
+//
+//	window — the Ebiten image buffer used for drawing.
+//
+// Returns:
+//
+//	error - if the pixels cannot be uploaded.
+func (w *World) Display(window *ebiten.Image) error {
+	if w.pixels == nil {
+		w.pixels = make([]byte, 4*w.width*scale*w.height*scale)
+	}
+	w.eachTile(func(worker int, startX int, endX int) {
+		w.paint(startX, endX)
 	})
-	for i := 0; i < numFish; i++ {
-		x := coords[i][0]
-		y := coords[i][1]
-		grid[x][y].typeId = 1
-		grid[x][y].breedTimer = fishBreed
+	return window.ReplacePixels(w.pixels)
+}
+
+// paint writes the colour of every square in columns startX to endX into the pixel buffer.
+//
+// Parameters:
+//
+//	startX - the first column to paint.
+//	endX - the column after the last one to paint.
+func (w *World) paint(startX int, endX int) {
+	stride := 4 * w.width * scale
+	for x := startX; x < endX; x++ {
+		for y := 0; y < w.height; y++ {
+			watorcommon.PaintSquare(w.pixels, stride, x, y, scale, int(w.gridAt(x, y).typeId))
+		}
 	}
-	for i := numFish; i < numShark+numFish; i++ {
-		x := coords[i][0]
-		y := coords[i][1]
-		grid[x][y].typeId = 2
-		grid[x][y].breedTimer = sharkBreed
-		grid[x][y].energy = starve
+}
+
+// Run opens a window and runs the concurrent simulation loop on this world until the window is closed.
+//
+// Returns:
+//
+//	error - if the window cannot be opened or Update fails.
+func (w *World) Run() error {
+	log.Printf("Seed : %d", w.Seed())
+	return ebiten.Run(w.Frame, w.width*scale, w.height*scale, 1, "Wa-tor Simulation (Concurrent)")
+}
+
+// RunConcurrent creates a world from the given parameters and starts the concurrent simulation loop.
+//
+// Parameters:
+//
+//	config - the simulation parameters.
+func RunConcurrent(config watorcommon.Config) {
+	w, err := NewWorld(config)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := ebiten.Run(Frame, width, height, 1, "Wa-tor Simulation (Concurrent)"); err != nil {
+	defer w.Close()
+	if err := w.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
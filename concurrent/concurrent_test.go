@@ -0,0 +1,117 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	watorcommon "help/common"
+	watorconcurrent "help/concurrent"
+)
+
+// TestUpdateKeepsInvariants runs the default and the asynchronous update with invariant checking on, so a fish
+// that survives being eaten or an animal lost in a move is reported as an error from Update.
+func TestUpdateKeepsInvariants(t *testing.T) {
+	for _, asynchronous := range []bool{false, true} {
+		name := "default"
+		if asynchronous {
+			name = "asynchronous"
+		}
+		t.Run(name, func(t *testing.T) {
+			config := watorcommon.Config{
+				Width: 96, Height: 72, NumFish: 1700, NumShark: 350, FishBreed: 5, SharkBreed: 10, Starve: 4,
+				EnergyGain: 2, Threads: 3, Seed: 11, Asynchronous: asynchronous, Check: true,
+			}
+			w, err := watorconcurrent.NewWorld(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			predations := 0
+			for chronon := 1; chronon <= 200; chronon++ {
+				if err := w.Update(); err != nil {
+					t.Fatalf("chronon %d: %v", chronon, err)
+				}
+				predations += w.Stats().Predations
+			}
+			if predations == 0 {
+				t.Fatal("no fish were eaten")
+			}
+		})
+	}
+}
+
+// runSeed runs a phased world for 100 chronons and returns its cells.
+//
+// Parameters:
+//
+//	seed - the seed to run.
+//
+// Returns:
+//
+//	[]watorcommon.Cell - the cells after the last chronon.
+//	error - if the world cannot be made or updated.
+func runSeed(seed uint64) ([]watorcommon.Cell, error) {
+	w, err := watorconcurrent.NewWorld(watorcommon.Config{
+		Width: 40, Height: 30, NumFish: 300, NumShark: 60, FishBreed: 5, SharkBreed: 10, Starve: 4, EnergyGain: 2,
+		Threads: 3, Seed: seed, Scheduler: watorcommon.SchedulerPhased,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	for i := 0; i < 100; i++ {
+		if err := w.Update(); err != nil {
+			return nil, err
+		}
+	}
+	return w.Snapshot().Cells, nil
+}
+
+// TestWorldsShareNoState runs worlds with different seeds at the same time, which must end exactly as they do when
+// each runs alone. Run with -race to also catch state the worlds share without changing the result.
+func TestWorldsShareNoState(t *testing.T) {
+	t.Parallel()
+	seeds := []uint64{1, 2}
+	alone := make([][]watorcommon.Cell, len(seeds))
+	for i, seed := range seeds {
+		var err error
+		if alone[i], err = runSeed(seed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	together := make([][]watorcommon.Cell, len(seeds))
+	errs := make([]error, len(seeds))
+	var wg sync.WaitGroup
+	for i, seed := range seeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			together[i], errs[i] = runSeed(seed)
+		}()
+	}
+	wg.Wait()
+	for i, seed := range seeds {
+		if errs[i] != nil {
+			t.Fatalf("seed %d: %v", seed, errs[i])
+		}
+		if !reflect.DeepEqual(together[i], alone[i]) {
+			t.Errorf("seed %d: the world ended differently when run alongside another", seed)
+		}
+	}
+}
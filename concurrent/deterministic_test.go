@@ -0,0 +1,67 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	watorcommon "help/common"
+	watorconcurrent "help/concurrent"
+	watorsequential "help/sequential"
+)
+
+// TestDeterministicMatchesSequential runs the sequential engine and the concurrent engine at several thread counts
+// side by side in deterministic mode, and checks that every chronon they hold the same squares and count the same
+// events.
+func TestDeterministicMatchesSequential(t *testing.T) {
+	config := watorcommon.Config{
+		Width: 50, Height: 30, NumFish: 400, NumShark: 80, FishBreed: 5, SharkBreed: 10, Starve: 4, EnergyGain: 2,
+		Seed: 7, Deterministic: true, Check: true,
+	}
+	for _, threads := range []int{1, 2, 3, 7} {
+		t.Run(fmt.Sprintf("%d threads", threads), func(t *testing.T) {
+			config.Threads = threads
+			sequential, err := watorsequential.NewWorld(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			concurrent, err := watorconcurrent.NewWorld(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer concurrent.Close()
+			for chronon := 1; chronon <= 200; chronon++ {
+				if err := sequential.Update(); err != nil {
+					t.Fatalf("sequential chronon %d: %v", chronon, err)
+				}
+				if err := concurrent.Update(); err != nil {
+					t.Fatalf("concurrent chronon %d: %v", chronon, err)
+				}
+				// Only the concurrent engine measures how the work was shared out.
+				got := concurrent.Stats()
+				got.Imbalance, got.StaticImbalance = 0, 0
+				if want := sequential.Stats(); got != want {
+					t.Fatalf("chronon %d: stats %+v, want %+v", chronon, got, want)
+				}
+				if !reflect.DeepEqual(concurrent.Snapshot().Cells, sequential.Snapshot().Cells) {
+					t.Fatalf("chronon %d: the grids differ", chronon)
+				}
+			}
+		})
+	}
+}
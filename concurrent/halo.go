@@ -0,0 +1,156 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent
+
+// Sides of a tile, used to index worker.halo.
+const (
+	left = iota
+	right
+)
+
+// crossing is one move out of a tile, waiting in the sending worker's halo for the tile it moves into to accept
+// or reject it.
+//
+// Fields:
+//
+//	square	the animal as it arrives, an empty square if nothing crosses at this row.
+//	won		set by the receiving tile if the square was still free and the animal got it.
+type crossing struct {
+	square square
+	won    bool
+}
+
+// haloSweep moves every animal of one kind using the halo scheduler, in three steps with every worker finishing a
+// step before the next one starts:
+//
+//  1. Sweep: every worker moves the animals in its own tile. Moves inside the tile are
+//     written straight to the buffer, which only this worker writes. Moves into a neighbouring tile are written
+//     to the worker's halo instead and treated as successful for now.
+//  2. Exchange: every worker reads the halos of its two neighbours that face its tile and accepts each incoming
+//     animal whose square is still free, just as SafeWrite would have. Its own animals moved first, so they win.
+//  3. Settle: every worker looks at the verdicts on its own halo and puts every rejected animal back where it
+//     started, undoing the births and predations counted for it during the sweep.
+//
+// No square is written by two workers in the same step, so no locks are needed. A rejected animal stays where it
+// was rather than trying another square. Each worker always handles the same tile and the exchange always runs
+// in the same order, so for a given seed and number of threads the run is reproducible. The caller waits at the
+// barrier after the settle step.
+//
+// Parameters:
+//
+//	worker - the worker doing the sweep.
+//	typeId - the animals to move, 2 for sharks and 1 for fish.
+func (w *World) haloSweep(worker int, typeId int) {
+	startX, endX := w.starts[worker], w.starts[worker+1]
+	w.workers[worker].load += w.sweep(startX, endX, 0, w.height, worker, w.starts, typeId)
+	w.barrier.wait()
+	w.exchange(worker, startX, endX)
+	w.barrier.wait()
+	w.settleHalo(worker, startX, endX)
+}
+
+// send holds back a move out of a worker's tile in the worker's halo. An animal only moves one column, so a
+// target left of the tile can only have come from the tile's first column and one right of it from the last.
+//
+// Parameters:
+//
+//	x - x coordinate of the target square, in a neighbouring tile.
+//	y - y coordinate of the target square.
+//	square - the animal as it arrives.
+//	worker - the worker making the move.
+func (w *World) send(x int, y int, square square, worker int) {
+	side := right
+	if x == (w.starts[worker]-1+w.width)%w.width {
+		side = left
+	}
+	w.workers[worker].halo[side][y] = crossing{square: square}
+}
+
+// exchange accepts the animals the neighbouring tiles sent into a tile's first and last column, writing each one
+// into the buffer if its square is still free and recording the verdict in the sender's halo.
+//
+// Parameters:
+//
+//	worker - the tile receiving the animals.
+//	startX - first column of the tile.
+//	endX - one past the last column of the tile.
+func (w *World) exchange(worker int, startX int, endX int) {
+	fromLeft := w.workers[(worker-1+w.threads)%w.threads].halo[right]
+	fromRight := w.workers[(worker+1)%w.threads].halo[left]
+	for y := 0; y < w.height; y++ {
+		w.accept(&fromLeft[y], startX, y)
+		w.accept(&fromRight[y], endX-1, y)
+	}
+}
+
+// accept writes an animal sent by a neighbouring tile into the buffer if its square is still free.
+//
+// Parameters:
+//
+//	sent - the move, marked as won if the animal gets the square.
+//	x - x coordinate of the target square.
+//	y - y coordinate of the target square.
+func (w *World) accept(sent *crossing, x int, y int) {
+	if sent.square.typeId != 0 && w.bufferAt(x, y).typeId == 0 {
+		w.setBuffer(x, y, sent.square)
+		sent.won = true
+	}
+}
+
+// settleHalo puts every animal a tile sent that was rejected back on its starting square, in the first or last
+// column of the tile, and clears the halo ready for the next sweep. A rejected animal counts as a blocked move. A
+// baby it left behind is not born after all, a shark that was going to eat does not, and a shark that needed that
+// meal starves.
+//
+// Parameters:
+//
+//	worker - the tile whose halo is settled.
+//	startX - first column of the tile.
+//	endX - one past the last column of the tile.
+func (w *World) settleHalo(worker int, startX int, endX int) {
+	stats := &w.workers[worker].stats
+	for side, fromX := range [2]int{startX, endX - 1} {
+		toX := (fromX - 1 + w.width) % w.width
+		if side == right {
+			toX = (fromX + 1) % w.width
+		}
+		halo := w.workers[worker].halo[side]
+		for y := range halo {
+			sent := halo[y]
+			halo[y] = crossing{}
+			if sent.square.typeId == 0 || sent.won {
+				continue
+			}
+			stats.BlockedMoves++
+			stay := sent.square
+			if stay.typeId == 2 && w.gridAt(toX, y).typeId == 1 {
+				stay.energy -= w.energyGain
+				stats.Predations--
+			}
+			switch w.bufferAt(fromX, y).typeId {
+			case 1:
+				stats.FishBirths--
+			case 2:
+				stats.SharkBirths--
+			}
+			if stay.typeId == 2 && stay.energy <= 0 {
+				stats.Starvations++
+				stay = square{}
+			}
+			w.setBuffer(fromX, y, stay)
+		}
+	}
+}
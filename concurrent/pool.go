@@ -0,0 +1,131 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent
+
+import "sync"
+
+// pool is a fixed set of long-lived goroutines, one per worker, that a World hands its work to instead of starting
+// new goroutines for every step. Worker i always runs on the same goroutine.
+//
+// Fields:
+//
+//	tasks	one channel per worker, each worker's goroutine runs every task sent on its channel.
+//	done	counts down as the workers finish the current task.
+//	closed	whether close has been called.
+type pool struct {
+	tasks  []chan func(worker int)
+	done   sync.WaitGroup
+	closed bool
+}
+
+// newPool starts one goroutine per worker, each waiting for its first task.
+//
+// Parameters:
+//
+//	workers - the number of goroutines to start.
+//
+// Returns:
+//
+//	*pool - the running pool.
+func newPool(workers int) *pool {
+	p := &pool{tasks: make([]chan func(worker int), workers)}
+	for worker := range p.tasks {
+		p.tasks[worker] = make(chan func(worker int))
+		go func(worker int, tasks chan func(worker int)) {
+			for task := range tasks {
+				task(worker)
+				p.done.Done()
+			}
+		}(worker, p.tasks[worker])
+	}
+	return p
+}
+
+// run gives every worker the same task and waits until they have all finished it.
+//
+// Parameters:
+//
+//	task - called once on every worker's goroutine with the worker number.
+func (p *pool) run(task func(worker int)) {
+	p.done.Add(len(p.tasks))
+	for _, tasks := range p.tasks {
+		tasks <- task
+	}
+	p.done.Wait()
+}
+
+// close stops every goroutine once it has finished its current task. The pool cannot be used afterwards, closing it
+// again does nothing.
+func (p *pool) close() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, tasks := range p.tasks {
+		close(tasks)
+	}
+}
+
+// barrier makes a fixed number of goroutines wait for each other. It can be used again as soon as every goroutine
+// has been let through, so the workers of a pool can step through the phases of a chronon together.
+//
+// Fields:
+//
+//	mu			guards the other fields.
+//	arrived		signalled when the last goroutine arrives.
+//	parties		the number of goroutines that have to arrive.
+//	waiting		the number that have arrived so far.
+//	generation	counts how many times the barrier has opened.
+type barrier struct {
+	mu         sync.Mutex
+	arrived    *sync.Cond
+	parties    int
+	waiting    int
+	generation int
+}
+
+// newBarrier creates a barrier for the given number of goroutines.
+//
+// Parameters:
+//
+//	parties - the number of goroutines that have to arrive before any is let through.
+//
+// Returns:
+//
+//	*barrier - the barrier.
+func newBarrier(parties int) *barrier {
+	b := &barrier{parties: parties}
+	b.arrived = sync.NewCond(&b.mu)
+	return b
+}
+
+// wait blocks until every goroutine has called wait, then lets them all through. Everything a goroutine wrote
+// before calling wait is visible to every goroutine after it.
+func (b *barrier) wait() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	generation := b.generation
+	b.waiting++
+	if b.waiting == b.parties {
+		b.waiting = 0
+		b.generation++
+		b.arrived.Broadcast()
+		return
+	}
+	for generation == b.generation {
+		b.arrived.Wait()
+	}
+}
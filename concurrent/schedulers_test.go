@@ -0,0 +1,116 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorconcurrent_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	watorcommon "help/common"
+	watorconcurrent "help/concurrent"
+)
+
+// schedulerConfig is a small world with both kinds of animal for the scheduler tests.
+//
+// Parameters:
+//
+//	scheduler - the scheduler to run.
+//	threads - the number of threads.
+//
+// Returns:
+//
+//	watorcommon.Config - the config, with invariant checking on.
+func schedulerConfig(scheduler watorcommon.Scheduler, threads int) watorcommon.Config {
+	return watorcommon.Config{
+		Width: 40, Height: 30, NumFish: 300, NumShark: 60, FishBreed: 5, SharkBreed: 10, Starve: 4, EnergyGain: 2,
+		Threads: threads, Seed: 3, Scheduler: scheduler, Check: true,
+	}
+}
+
+// TestSchedulersKeepInvariants runs every scheduler on the default update path with invariant checking on, at
+// several thread counts and with the tile sizes and balancing it supports.
+func TestSchedulersKeepInvariants(t *testing.T) {
+	tests := []struct {
+		name       string
+		scheduler  watorcommon.Scheduler
+		tileWidth  int
+		tileHeight int
+		balance    bool
+	}{
+		{name: "phased", scheduler: watorcommon.SchedulerPhased},
+		{name: "halo", scheduler: watorcommon.SchedulerHalo},
+		{name: "locked automatic tiles", scheduler: watorcommon.SchedulerLocked},
+		{name: "locked 7x13 tiles", scheduler: watorcommon.SchedulerLocked, tileWidth: 7, tileHeight: 13},
+		{name: "locked full height tiles", scheduler: watorcommon.SchedulerLocked, tileWidth: 6},
+		{name: "locked full width tiles", scheduler: watorcommon.SchedulerLocked, tileHeight: 4},
+		{name: "locked automatic tiles balanced", scheduler: watorcommon.SchedulerLocked, balance: true},
+		{name: "locked 7x13 tiles balanced", scheduler: watorcommon.SchedulerLocked, tileWidth: 7, tileHeight: 13, balance: true},
+		{name: "phased balanced", scheduler: watorcommon.SchedulerPhased, balance: true},
+		{name: "halo balanced", scheduler: watorcommon.SchedulerHalo, balance: true},
+	}
+	for _, test := range tests {
+		for _, threads := range []int{1, 2, 3, 5} {
+			t.Run(fmt.Sprintf("%s/%d threads", test.name, threads), func(t *testing.T) {
+				config := schedulerConfig(test.scheduler, threads)
+				config.TileWidth, config.TileHeight, config.Balance = test.tileWidth, test.tileHeight, test.balance
+				w, err := watorconcurrent.NewWorld(config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer w.Close()
+				for chronon := 1; chronon <= 60; chronon++ {
+					if err := w.Update(); err != nil {
+						t.Fatalf("chronon %d: %v", chronon, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestSchedulersRepeat checks that the schedulers that promise it give the same grid every chronon when run twice
+// with the same seed and number of threads.
+func TestSchedulersRepeat(t *testing.T) {
+	for _, scheduler := range []watorcommon.Scheduler{
+		watorcommon.SchedulerPhased,
+		watorcommon.SchedulerHalo,
+	} {
+		t.Run(scheduler.String(), func(t *testing.T) {
+			first, err := watorconcurrent.NewWorld(schedulerConfig(scheduler, 3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer first.Close()
+			second, err := watorconcurrent.NewWorld(schedulerConfig(scheduler, 3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer second.Close()
+			for chronon := 1; chronon <= 100; chronon++ {
+				if err := first.Update(); err != nil {
+					t.Fatal(err)
+				}
+				if err := second.Update(); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(first.Snapshot().Cells, second.Snapshot().Cells) {
+					t.Fatalf("chronon %d: the grids differ", chronon)
+				}
+			}
+		})
+	}
+}
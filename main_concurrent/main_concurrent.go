@@ -1,7 +0,0 @@
-package main
-
-import watorconcurrent "help/concurrent"
-
-func main() {
-	watorconcurrent.RunConcurrent()
-}
@@ -1,7 +0,0 @@
-package main
-
-import watorsequential "help/sequential"
-
-func main() {
-	watorsequential.RunSequential()
-}
@@ -0,0 +1,135 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorsequential
+
+// AsynchronousUpdate computes the next chronon in place, the way Dewdney's original Wa-Tor does. Every animal
+// alive at the start of the chronon acts once, one at a time in a random order, and each move changes grid
+// straight away so the next animal sees it. The buffer is not used, so no move is ever lost to a conflict.
+// Animals that have already moved and animals born this chronon do not act again, and a fish that is eaten
+// before its turn never gets one. A sparse world takes the animals from its list of live animals instead of
+// scanning the grid for them
+func (w *World) AsynchronousUpdate() {
+	w.order = w.order[:0]
+	if w.sparse {
+		for _, i := range w.live {
+			w.order = append(w.order, [2]int{i / w.height, i % w.height})
+		}
+	} else {
+		for x := 0; x < w.width; x++ {
+			for y := 0; y < w.height; y++ {
+				if w.gridAt(x, y).typeId != 0 {
+					w.order = append(w.order, [2]int{x, y})
+				}
+			}
+		}
+	}
+	w.rng.Shuffle(len(w.order), func(i, j int) {
+		w.order[i], w.order[j] = w.order[j], w.order[i]
+	})
+	clear(w.acted)
+	for _, position := range w.order {
+		x, y := position[0], position[1]
+		if w.acted[x*w.height+y] {
+			continue
+		}
+		switch w.gridAt(x, y).typeId {
+		case 1:
+			w.actFish(x, y)
+		case 2:
+			w.actShark(x, y)
+		}
+	}
+}
+
+// actFish moves the fish at (x, y) in place, with the same rules as UpdateFish
+//
+// Parameters:
+//
+//	x - x coordinate of current fish square
+//	y - y coordinate of current fish square
+func (w *World) actFish(x int, y int) {
+	next := square{typeId: 1, breedTimer: w.gridAt(x, y).breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.fishBreed
+	}
+	freeSquares := w.GatherFreeSquares(x, y)
+	if len(freeSquares) == 0 {
+		w.settle(x, y, next)
+		return
+	}
+	target := freeSquares[w.rng.IntN(len(freeSquares))]
+	w.settle(target[0], target[1], next)
+	if breeds {
+		w.settle(x, y, square{typeId: 1, breedTimer: w.fishBreed})
+		w.stats.FishBirths++
+	} else {
+		w.setGrid(x, y, square{})
+	}
+}
+
+// actShark moves the shark at (x, y) in place, with the same rules as UpdateSharks. A fish it eats is gone at once
+//
+// Parameters:
+//
+//	x - x coordinate of current shark square
+//	y - y coordinate of current shark square
+func (w *World) actShark(x int, y int) {
+	current := w.gridAt(x, y)
+	next := square{typeId: 2, energy: current.energy - 1, breedTimer: current.breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.sharkBreed
+	}
+	var targets [][2]int
+	if next.energy+w.energyGain > 0 {
+		targets = w.GatherFishSquares(x, y)
+	}
+	if len(targets) > 0 {
+		next.energy += w.energyGain
+		w.stats.Predations++
+	} else if next.energy <= 0 {
+		w.setGrid(x, y, square{})
+		w.stats.Starvations++
+		return
+	} else {
+		targets = w.GatherFreeSquares(x, y)
+	}
+	if len(targets) == 0 {
+		w.settle(x, y, next)
+		return
+	}
+	target := targets[w.rng.IntN(len(targets))]
+	w.settle(target[0], target[1], next)
+	if breeds {
+		w.settle(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed})
+		w.stats.SharkBirths++
+	} else {
+		w.setGrid(x, y, square{})
+	}
+}
+
+// settle puts an animal that has acted this chronon onto a square of grid, so it does not act again
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//	next - the animal as it will be next chronon
+func (w *World) settle(x int, y int, next square) {
+	w.setGrid(x, y, next)
+	w.acted[x*w.height+y] = true
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorsequential
+
+// retagInterval is how many generations pass between calls to retag. A square's tag is 8 bits, so a square left
+// untouched for 256 generations would carry the current tag again. Retagging every 128 keeps every tag well within
+// the last 256 generations
+const retagInterval = 128
+
+// gridAt reads a square of the current state of the world. A square left over from an older generation is empty
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//
+// Returns:
+//
+//	square - what the square holds
+func (w *World) gridAt(x int, y int) square {
+	current := w.grid[x][y]
+	if current.generation != w.generation {
+		return square{}
+	}
+	return current
+}
+
+// bufferAt reads a square of the next state of the world, as written so far this chronon
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//
+// Returns:
+//
+//	square - what the square holds, empty if nothing has been written to it yet
+func (w *World) bufferAt(x int, y int) square {
+	next := w.buffer[x][y]
+	if next.generation != w.generation+1 {
+		return square{}
+	}
+	return next
+}
+
+// setGrid writes a square of the current state of the world, used where the grid is changed in place
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//	current - what the square holds
+func (w *World) setGrid(x int, y int, current square) {
+	current.generation = w.generation
+	w.grid[x][y] = current
+}
+
+// setBuffer writes a square of the next state of the world
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//	next - what the square holds next chronon
+func (w *World) setBuffer(x int, y int, next square) {
+	next.generation = w.generation + 1
+	w.buffer[x][y] = next
+}
+
+// swap makes the buffer the current state of the world. Moving on a generation turns every square in the old grid
+// stale at once, so the new buffer reads as empty without being cleared
+func (w *World) swap() {
+	w.grid, w.buffer = w.buffer, w.grid
+	w.generation++
+	if w.generation%retagInterval == 0 {
+		w.retag()
+	}
+}
+
+// retag rewrites every square in both grids with a recent tag, so that no stale square lives long enough for its
+// tag to come round again. Squares in the grid keep what they hold, and every square in the buffer is emptied
+func (w *World) retag() {
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			w.setGrid(x, y, w.gridAt(x, y))
+		}
+		clear(w.buffer[x])
+	}
+}
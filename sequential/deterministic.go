@@ -0,0 +1,311 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorsequential
+
+import watorcommon "help/common"
+
+// Directions used by the deterministic update, in the same order GatherFreeSquares checks them. stay means the
+// animal is not moving (or, for winner, that nothing won the square)
+const (
+	stay uint8 = iota
+	north
+	west
+	east
+	south
+)
+
+// newDirections allocates a width x height grid of directions, laid out the same way as newGrid
+//
+// Parameters:
+//
+//	width - number of columns
+//	height - number of rows
+//
+// Returns:
+//
+//	[][]uint8 - the grid, indexed as directions[x][y]
+func newDirections(width int, height int) [][]uint8 {
+	cells := make([]uint8, width*height)
+	directions := make([][]uint8, width)
+	for x := range directions {
+		directions[x] = cells[x*height : (x+1)*height]
+	}
+	return directions
+}
+
+// neighbour returns the coordinates of the square one step from (x, y) in the given direction, wrapping around
+// the edges of the grid
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+//	direction - one of north, west, east or south. stay returns (x, y)
+//
+// Returns:
+//
+//	int, int - the coordinates of the neighbouring square
+func (w *World) neighbour(x int, y int, direction uint8) (int, int) {
+	switch direction {
+	case north:
+		return x, (y - 1 + w.height) % w.height
+	case west:
+		return (x - 1 + w.width) % w.width, y
+	case east:
+		return (x + 1) % w.width, y
+	case south:
+		return x, (y + 1) % w.height
+	}
+	return x, y
+}
+
+// DeterministicUpdate computes the next chronon into the buffer in four passes over the grid. Every pass only
+// reads what earlier passes wrote and only writes the square it is visiting, so the outcome does not depend on
+// the order the squares are visited in. The concurrent engine runs the same passes tile by tile and gets the
+// same result for any number of threads.
+//
+//  1. Propose: every animal picks the square it wants using watorcommon.CellRandom.
+//  2. Resolve prey: every fish square is won by the highest priority shark that wants it, and that fish is eaten.
+//  3. Resolve moves: every empty square is won by the highest priority animal that wants it, ignoring eaten fish.
+//  4. Finalise: every square works out what it holds next chronon from who won what.
+//
+// Animals that lose a square stay where they are. Nothing else can want their square, since fish only move to
+// empty squares and sharks only move to empty or fish squares.
+func (w *World) DeterministicUpdate() {
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			w.propose(x, y)
+		}
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			w.resolvePrey(x, y)
+		}
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			w.resolveMoves(x, y)
+		}
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			w.finalise(x, y, &w.stats)
+		}
+	}
+}
+
+// propose records the direction the animal at (x, y) wants to move in. Sharks pick a neighbouring fish if there
+// is one and a free square otherwise, fish pick a free square. Only grid is read, never buffer.
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+func (w *World) propose(x int, y int) {
+	w.direction[x][y] = stay
+	var options [][2]int
+	switch w.gridAt(x, y).typeId {
+	case 0:
+		return
+	case 2:
+		options = w.GatherFishSquares(x, y)
+	}
+	if len(options) == 0 {
+		options = w.GatherFreeSquares(x, y)
+	}
+	if len(options) == 0 {
+		return
+	}
+	chosen := options[watorcommon.CellRandom(w.seed, w.chronon, x, y)%uint64(len(options))]
+	for direction := north; direction <= south; direction++ {
+		if nx, ny := w.neighbour(x, y, direction); nx == chosen[0] && ny == chosen[1] {
+			w.direction[x][y] = direction
+			return
+		}
+	}
+}
+
+// resolvePrey records which shark, if any, eats the fish at (x, y). Every other square is reset to no winner
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+func (w *World) resolvePrey(x int, y int) {
+	w.winner[x][y] = stay
+	if w.gridAt(x, y).typeId == 1 {
+		w.winner[x][y] = w.claim(x, y)
+	}
+}
+
+// resolveMoves records which animal, if any, moves onto the empty square at (x, y)
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+func (w *World) resolveMoves(x int, y int) {
+	if w.gridAt(x, y).typeId == 0 {
+		w.winner[x][y] = w.claim(x, y)
+	}
+}
+
+// claim looks at the neighbours that want to move onto (x, y) and returns the direction of the one with the
+// highest priority. On a fish square only sharks count. On an empty square fish that have been eaten do not.
+//
+// Parameters:
+//
+//	x - x coordinate of the contested square
+//	y - y coordinate of the contested square
+//
+// Returns:
+//
+//	uint8 - direction from (x, y) to the winning animal, stay if no animal wants the square
+func (w *World) claim(x int, y int) uint8 {
+	best := stay
+	var bestPriority uint64
+	for direction := north; direction <= south; direction++ {
+		nx, ny := w.neighbour(x, y, direction)
+		if w.direction[nx][ny] == stay {
+			continue
+		}
+		if tx, ty := w.neighbour(nx, ny, w.direction[nx][ny]); tx != x || ty != y {
+			continue
+		}
+		if w.gridAt(x, y).typeId == 1 && w.gridAt(nx, ny).typeId != 2 {
+			continue
+		}
+		if w.gridAt(x, y).typeId == 0 && w.gridAt(nx, ny).typeId == 1 && w.winner[nx][ny] != stay {
+			continue
+		}
+		priority := watorcommon.CellPriority(w.seed, w.chronon, nx, ny)
+		if best == stay || priority > bestPriority {
+			best = direction
+			bestPriority = priority
+		}
+	}
+	return best
+}
+
+// moved reports whether the animal at (x, y) won the square it proposed
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+//
+// Returns:
+//
+//	bool - true if the animal leaves (x, y) this chronon
+func (w *World) moved(x int, y int) bool {
+	if w.direction[x][y] == stay {
+		return false
+	}
+	tx, ty := w.neighbour(x, y, w.direction[x][y])
+	if w.winner[tx][ty] == stay {
+		return false
+	}
+	fx, fy := w.neighbour(tx, ty, w.winner[tx][ty])
+	return fx == x && fy == y
+}
+
+// arrive returns what an animal coming from (fromX, fromY) becomes once it has made its move, applying the same
+// ageing, starving and breeding rules as UpdateFish and UpdateSharks
+//
+// Parameters:
+//
+//	fromX - x coordinate the animal started on
+//	fromY - y coordinate the animal started on
+//	ate - whether the animal is a shark that ate a fish on the way
+//
+// Returns:
+//
+//	square - the animal after its move, an empty square if it starved
+//	bool - whether the animal breeds, leaving a new one behind if it moved
+func (w *World) arrive(fromX int, fromY int, ate bool) (square, bool) {
+	current := w.gridAt(fromX, fromY)
+	next := square{typeId: current.typeId, breedTimer: current.breedTimer - 1}
+	if current.typeId == 2 {
+		next.energy = current.energy - 1
+		if ate {
+			next.energy += w.energyGain
+		}
+		if next.energy <= 0 {
+			return square{}, false
+		}
+	}
+	if next.breedTimer > 0 {
+		return next, false
+	}
+	if next.typeId == 1 {
+		next.breedTimer = w.fishBreed
+	} else {
+		next.breedTimer = w.sharkBreed
+	}
+	return next, true
+}
+
+// finalise writes what (x, y) holds next chronon into the buffer, counting any predation, starvation, birth or
+// blocked move that happens to the animal that started there
+//
+// Parameters:
+//
+//	x - x coordinate of current square
+//	y - y coordinate of current square
+//	stats - the stats to count events in
+func (w *World) finalise(x int, y int, stats *watorcommon.Stats) {
+	current := w.gridAt(x, y)
+	if current.typeId == 0 || (current.typeId == 1 && w.winner[x][y] != stay) {
+		w.setBuffer(x, y, square{})
+		if w.winner[x][y] != stay {
+			if current.typeId == 1 {
+				stats.Predations++
+			}
+			fromX, fromY := w.neighbour(x, y, w.winner[x][y])
+			arrived, _ := w.arrive(fromX, fromY, current.typeId == 1)
+			w.setBuffer(x, y, arrived)
+		}
+		return
+	}
+	if !w.moved(x, y) {
+		if w.direction[x][y] != stay {
+			stats.BlockedMoves++
+		}
+		arrived, _ := w.arrive(x, y, false)
+		w.setBuffer(x, y, arrived)
+		if arrived.typeId == 0 {
+			stats.Starvations++
+		}
+		return
+	}
+	w.setBuffer(x, y, square{})
+	targetX, targetY := w.neighbour(x, y, w.direction[x][y])
+	next, breeds := w.arrive(x, y, w.gridAt(targetX, targetY).typeId == 1)
+	if next.typeId == 0 {
+		stats.Starvations++
+		return
+	}
+	if !breeds {
+		return
+	}
+	if current.typeId == 1 {
+		w.setBuffer(x, y, square{typeId: 1, breedTimer: w.fishBreed})
+		stats.FishBirths++
+	} else {
+		w.setBuffer(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed})
+		stats.SharkBirths++
+	}
+}
@@ -19,68 +19,245 @@
 package watorsequential
 
 import (
-	"image/color"
+	"fmt"
 	"log"
 	"math/rand/v2"
 	"time"
 
+	watorcommon "help/common"
+
 	"github.com/hajimehoshi/ebiten"
 )
 
 // scale defines the drawing scale for each cell
-const scale = 1
-
-// width and height define the size of the simulation grid
-const width = 1800
-const height = 1000
-
-var blue color.Color = color.RGBA{69, 145, 196, 255}
-var yellow color.Color = color.RGBA{255, 230, 120, 255}
-var red color.Color = color.RGBA{255, 50, 50, 255}
-
-// buffer is a temporary grid used for writing the updated state of the world
-var buffer [width][height]square = [width][height]square{}
-var count int = 0
-
-// numShark is the number of sharks the simultaion starts with
-var numShark int = 100
-
-// numFish is the number of fish the simultaion starts with
-var numFish int = 200000
-
-// fishBreed is the number of simulation steps it takes for a fish to reproduce
-var fishBreed int = 5
-
-// sharkBreed is the number of simultion steps it takes for a shark to reproduce
-var sharkBreed int = 10
-
-// starve is the number of simulation steps it takes for a shark to starve
-var starve int = 4
-
-// energyGain is how much energy a shark gains after eating a fish
-var energyGain = 2
-
-// grid represents the current state of the world
-var grid [width][height]square = [width][height]square{}
+const scale = watorcommon.Scale
 
-// square represents a cell in the simulation grid
+// square represents a cell in the simulation grid, packed into 8 bytes so a cache line holds eight of them
 //
 // Fields:
 //
 //	typeId		0 = empty space, 1 = fish, 2 = shark
-//	energy		shark energy
+//	generation	the generation the square was written in, a square from an older one reads as empty
 //	breedTimer	defines how long a fish or shark must live before breeding
+//	energy		shark energy
 type square struct {
-	typeId     int
-	energy     int
-	breedTimer int
+	typeId     uint8
+	generation uint8
+	breedTimer int16
+	energy     int32
 }
 
-// chronon is used for tracking simulation steps
-var chronon int = 0
+// World can be driven by watorcommon.RunHeadless as well as by Frame
+var _ watorcommon.Engine = (*World)(nil)
 
-// start is used for tracking elapsed time for measuring performance
-var start = time.Now()
+// newGrid allocates a width x height grid of empty squares. Every column is a slice of one shared backing array
+// so the whole grid stays contiguous in memory
+//
+// Parameters:
+//
+//	width - number of columns
+//	height - number of rows
+//
+// Returns:
+//
+//	[][]square - the grid, indexed as grid[x][y]
+func newGrid(width int, height int) [][]square {
+	cells := make([]square, width*height)
+	grid := make([][]square, width)
+	for x := range grid {
+		grid[x] = cells[x*height : (x+1)*height]
+	}
+	return grid
+}
+
+// World holds everything one sequential simulation needs, so several worlds can run side by side
+//
+// Fields:
+//
+//	width		the number of columns in the simulation grid
+//	height		the number of rows in the simulation grid
+//	grid		represents the current state of the world
+//	buffer		a temporary grid used for writing the updated state of the world
+//	generation	the tag a square in grid must carry to count, a square in buffer must carry one more
+//	numShark	the number of sharks the simultaion starts with
+//	numFish		the number of fish the simultaion starts with
+//	fishBreed	the number of simulation steps it takes for a fish to reproduce
+//	sharkBreed	the number of simultion steps it takes for a shark to reproduce
+//	starve		the number of simulation steps it takes for a shark to starve
+//	energyGain	how much energy a shark gains after eating a fish
+//	threads		the Threads parameter. Unused by the sequential engine, but kept so a snapshot resumes with it
+//	seed		the seed every random choice in this world derives from
+//	source		the random number source behind rng, kept so its state can be saved in a snapshot
+//	rng			the random number generator every random choice is made with
+//	chronon		the number of simulation steps completed so far
+//	stats		what happened during the most recent Update
+//	start		used for tracking elapsed time for measuring performance
+//	pixels		the RGBA bytes Display uploads to the window, allocated by the first Display
+//	deterministic	whether Update uses DeterministicUpdate
+//	asynchronous	whether Update uses AsynchronousUpdate
+//	order		the random order animals act in this chronon, only used by AsynchronousUpdate
+//	acted		which squares hold an animal that has acted this chronon, only used by AsynchronousUpdate
+//	check		whether Update finishes with watorcommon.CheckInvariants
+//	direction	the move each animal proposed this chronon, only used by DeterministicUpdate
+//	winner		which neighbour won each contested square this chronon, only used by DeterministicUpdate
+//	dense		never turn sparse, see track
+//	sparse		whether Update visits only the squares on live instead of scanning the whole grid, see track
+//	live		the squares holding an animal, numbered x*height+y and sorted, only kept while sparse
+//	candidates	the squares census looks at, kept to reuse its memory
+type World struct {
+	width      int
+	height     int
+	grid       [][]square
+	buffer     [][]square
+	generation uint8
+	count      int
+	numShark   int
+	numFish    int
+	fishBreed  int16
+	sharkBreed int16
+	starve     int32
+	energyGain int32
+	threads    int
+	seed       uint64
+	source     *rand.PCG
+	rng        *rand.Rand
+	chronon    int
+	stats      watorcommon.Stats
+	start      time.Time
+	pixels     []byte
+
+	deterministic bool
+	asynchronous  bool
+	order         [][2]int
+	acted         []bool
+	check         bool
+	direction     [][]uint8
+	winner        [][]uint8
+	dense         bool
+	sparse        bool
+	live          []int
+	candidates    []int
+}
+
+// NewWorld creates a world from the given parameters and scatters the starting fish and sharks across it at random
+//
+// Parameters:
+//
+//	config - the simulation parameters
+//
+// Returns:
+//
+//	*World - the populated world
+//	error - a *watorcommon.ConfigError for each invalid parameter, nil otherwise
+func NewWorld(config watorcommon.Config) (*World, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	w := newWorld(config)
+	coords := [][2]int{}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			coords = append(coords, [2]int{x, y})
+		}
+	}
+	w.rng.Shuffle(len(coords), func(i, j int) {
+		coords[i], coords[j] = coords[j], coords[i]
+	})
+	for i := 0; i < w.numFish; i++ {
+		x := coords[i][0]
+		y := coords[i][1]
+		w.setGrid(x, y, square{typeId: 1, breedTimer: w.fishBreed})
+	}
+	for i := w.numFish; i < w.numShark+w.numFish; i++ {
+		x := coords[i][0]
+		y := coords[i][1]
+		w.setGrid(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed})
+	}
+	w.track(w.numFish + w.numShark)
+	return w, nil
+}
+
+// NewWorldFromSnapshot recreates a world from a snapshot taken by either engine. The random number state is
+// restored when the snapshot holds exactly one, otherwise a fresh source is derived from the seed and chronon so
+// the resumed run is still repeatable
+//
+// Parameters:
+//
+//	snapshot - the snapshot to resume from
+//
+// Returns:
+//
+//	*World - the world as it was when the snapshot was taken
+//	error - a *watorcommon.ConfigError for each invalid parameter, or watorcommon.ErrCorruptSnapshot
+func NewWorldFromSnapshot(snapshot *watorcommon.Snapshot) (*World, error) {
+	if err := snapshot.Config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(snapshot.Cells) != snapshot.Config.Width*snapshot.Config.Height {
+		return nil, fmt.Errorf("%w: %d cells for a %dx%d grid", watorcommon.ErrCorruptSnapshot,
+			len(snapshot.Cells), snapshot.Config.Width, snapshot.Config.Height)
+	}
+	w := newWorld(snapshot.Config)
+	w.chronon = snapshot.Chronon
+	if len(snapshot.RNG) == 1 {
+		if err := w.source.UnmarshalBinary(snapshot.RNG[0]); err != nil {
+			return nil, fmt.Errorf("%w: %v", watorcommon.ErrCorruptSnapshot, err)
+		}
+	} else {
+		w.source = watorcommon.NewResumeSource(w.seed, w.chronon, 0)
+		w.rng = rand.New(w.source)
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			cell := snapshot.Cells[x*w.height+y]
+			w.setGrid(x, y, square{typeId: uint8(cell.TypeId), energy: int32(cell.Energy), breedTimer: int16(cell.BreedTimer)})
+		}
+	}
+	fish, sharks := w.Population()
+	w.track(fish + sharks)
+	return w, nil
+}
+
+// newWorld allocates an empty world for parameters that have already been validated
+//
+// Parameters:
+//
+//	config - the simulation parameters
+//
+// Returns:
+//
+//	*World - the world, with nothing in the grid
+func newWorld(config watorcommon.Config) *World {
+	w := &World{
+		width:      config.Width,
+		height:     config.Height,
+		grid:       newGrid(config.Width, config.Height),
+		buffer:     newGrid(config.Width, config.Height),
+		numShark:   config.NumShark,
+		numFish:    config.NumFish,
+		fishBreed:  int16(config.FishBreed),
+		sharkBreed: int16(config.SharkBreed),
+		starve:     int32(config.Starve),
+		energyGain: int32(config.EnergyGain),
+		threads:    config.Threads,
+		seed:       watorcommon.PickSeed(config.Seed),
+		start:      time.Now(),
+		dense:      config.Dense,
+		check:      config.Check,
+	}
+	w.source = watorcommon.NewSource(w.seed, 0)
+	w.rng = rand.New(w.source)
+	if config.Deterministic {
+		w.deterministic = true
+		w.direction = newDirections(w.width, w.height)
+		w.winner = newDirections(w.width, w.height)
+	}
+	if config.Asynchronous {
+		w.asynchronous = true
+		w.acted = make([]bool, w.width*w.height)
+	}
+	return w
+}
 
 // Frame updates the simulation each Frame by calling the Update() function and the Display() function
 //
@@ -90,23 +267,22 @@ var start = time.Now()
 //
 // Returns:
 //
-//	error - if the Update step fails, nil otherwise
-func Frame(window *ebiten.Image) error {
-	count++
-	chronon++
+//	error - if the Update or Display step fails, nil otherwise
+func (w *World) Frame(window *ebiten.Image) error {
+	w.count++
 	var err error = nil
 
-	if count == 1 {
-		err = Update()
-		count = 0
+	if w.count == 1 {
+		err = w.Update()
+		w.count = 0
 	}
-	if !ebiten.IsDrawingSkipped() {
-		Display(window)
+	if err == nil && !ebiten.IsDrawingSkipped() {
+		err = w.Display(window)
 	}
-	if chronon == 1000 {
-		var elapsed = time.Since(start)
+	if w.chronon%1000 == 0 {
+		var elapsed = time.Since(w.start)
 		log.Printf("Elapsed time for 1000 chronons : %s", elapsed)
-		chronon = 0
+		w.start = time.Now()
 	}
 
 	return err
@@ -123,22 +299,22 @@ func Frame(window *ebiten.Image) error {
 // Returns:
 //
 //	[][2]int - containing the coordinates of all free squares, if there are no free squares returns empty slice
-func GatherFreeSquares(x int, y int) [][2]int {
+func (w *World) GatherFreeSquares(x int, y int) [][2]int {
 	freeSquares := [][2]int{}
-	leftX := (x - 1 + width) % width
-	rightX := (x + 1) % width
-	upY := (y - 1 + height) % height
-	downY := (y + 1) % height
-	if grid[x][upY].typeId == 0 {
+	leftX := (x - 1 + w.width) % w.width
+	rightX := (x + 1) % w.width
+	upY := (y - 1 + w.height) % w.height
+	downY := (y + 1) % w.height
+	if w.gridAt(x, upY).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{x, upY})
 	}
-	if grid[leftX][y].typeId == 0 {
+	if w.gridAt(leftX, y).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{leftX, y})
 	}
-	if grid[rightX][y].typeId == 0 {
+	if w.gridAt(rightX, y).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{rightX, y})
 	}
-	if grid[x][downY].typeId == 0 {
+	if w.gridAt(x, downY).typeId == 0 {
 		freeSquares = append(freeSquares, [2]int{x, downY})
 	}
 	return freeSquares
@@ -156,33 +332,33 @@ func GatherFreeSquares(x int, y int) [][2]int {
 // Returns:
 //
 //	[][2]int - containing the coordinates of all fish squares, if there are no fish squares returns empty slice
-func GatherFishSquares(x int, y int) [][2]int {
+func (w *World) GatherFishSquares(x int, y int) [][2]int {
 	fishSquares := [][2]int{}
-	leftX := (x - 1 + width) % width
-	rightX := (x + 1) % width
-	upY := (y - 1 + height) % height
-	downY := (y + 1) % height
-	if grid[x][upY].typeId == 1 {
+	leftX := (x - 1 + w.width) % w.width
+	rightX := (x + 1) % w.width
+	upY := (y - 1 + w.height) % w.height
+	downY := (y + 1) % w.height
+	if w.gridAt(x, upY).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{x, upY})
 	}
-	if grid[leftX][y].typeId == 1 {
+	if w.gridAt(leftX, y).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{leftX, y})
 	}
-	if grid[rightX][y].typeId == 1 {
+	if w.gridAt(rightX, y).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{rightX, y})
 	}
-	if grid[x][downY].typeId == 1 {
+	if w.gridAt(x, downY).typeId == 1 {
 		fishSquares = append(fishSquares, [2]int{x, downY})
 	}
 	return fishSquares
 }
 
-// UpdateFish takes in the coordinates of a particular fish. It checks if this fish has been eaten yet
-// in the buffer. If not, GatherFreeSquares is called. if there are free squares, one is picked at random and
-// the buffer is checked to ensure that a different fish has not moved to the desired square already. If the
-// empty square is still free in the buffer, the fish is written to that square. otherwise the fish stays put.
-// UpdateFish also handles breeding by checking the moved fishes' breedtimer. if it is <=0 a new fish is
-// placed in it's old place and both fishes' breedTimers are reset.
+// UpdateFish takes in the coordinates of a particular fish. It runs after every shark has moved, so a fish whose
+// square already holds a shark in the buffer has been eaten and is skipped. Otherwise GatherFreeSquares is called.
+// if there are free squares, one is picked at random and moveTo attempts to write the fish there. If another
+// animal has already moved there, or there are no free squares, the fish stays put. UpdateFish also handles
+// breeding by checking the moved fishes' breedtimer. if it is <=0 a new fish is placed in it's old place and both
+// fishes' breedTimers are reset. A fish that cannot move does not breed, its breedTimer is reset instead.
 //
 // Parameters:
 //
@@ -192,41 +368,40 @@ func GatherFishSquares(x int, y int) [][2]int {
 // Returns:
 //
 //	nil
-func UpdateFish(x int, y int) error {
-	if buffer[x][y].typeId == 2 {
+func (w *World) UpdateFish(x int, y int) error {
+	if w.bufferAt(x, y).typeId == 2 {
 		return nil
 	}
-	freeSquares := GatherFreeSquares(x, y)
-	newX, newY := x, y
+	next := square{typeId: 1, breedTimer: w.gridAt(x, y).breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.fishBreed
+	}
+	freeSquares := w.GatherFreeSquares(x, y)
 	if len(freeSquares) > 0 {
-		newPosition := rand.IntN(len(freeSquares))
-		newX = freeSquares[newPosition][0]
-		newY = freeSquares[newPosition][1]
-		if buffer[newX][newY].typeId == 0 {
-			buffer[newX][newY].typeId = 1
-			buffer[newX][newY].breedTimer = grid[x][y].breedTimer - 1
-		} else {
-			buffer[x][y].typeId = grid[x][y].typeId
-			buffer[x][y].breedTimer = grid[x][y].breedTimer - 1
+		newPosition := w.rng.IntN(len(freeSquares))
+		if w.moveTo(freeSquares[newPosition][0], freeSquares[newPosition][1], next) {
+			if breeds {
+				w.moveTo(x, y, square{typeId: 1, breedTimer: w.fishBreed})
+				w.stats.FishBirths++
+			}
+			return nil
 		}
-	} else {
-		buffer[x][y].typeId = grid[x][y].typeId
-		buffer[x][y].breedTimer = grid[x][y].breedTimer - 1
-	}
-	if buffer[newX][newY].breedTimer <= 0 {
-		buffer[x][y].typeId = 1
-		buffer[x][y].breedTimer = fishBreed
-		buffer[newX][newY].breedTimer = fishBreed
+		w.stats.BlockedMoves++
 	}
+	w.moveTo(x, y, next)
 	return nil
 }
 
-// UpdateSharks takes in the coordinates of a particular shark. GatherFishSquares and GatherFreeSquares is called.
-// if there are adjacent fish squares one is picked at random and the buffer is checked to ensure that the fish
-// has not already been eaten by a different shark. If a shark cannot move to the desired fish square it attempts
-// to move to a free square. If there are no free squares the shark stays put. A shark loses 1 energy per turn and
-// gains a specified amount of energy upon eating a fish. If a sharks energy is <=0 it disappears. when a sharks
-// breedtimer is <=0 a new shark is placed at its old position and both sharks' breedTimers reset.
+// UpdateSharks takes in the coordinates of a particular shark. It runs before any fish has moved.
+// GatherFishSquares is called and if there are adjacent fish squares one is picked at random and moveTo attempts
+// to write the shark onto it. Only the first shark to reach a fish gets to write there, and that shark eats it:
+// the fish's square now holds a shark in the buffer, so UpdateFish skips the fish. If there are no fish squares,
+// or another shark got there first, a free square is picked at random instead, and if another animal has already
+// moved there too the shark stays put. A shark loses 1 energy per turn and gains a specified amount of energy upon
+// eating a fish. If a sharks energy is <=0 it disappears, and a shark that would starve even after eating does not
+// hunt. when a sharks breedtimer is <=0 and it moved a new shark is placed at its old position and both sharks'
+// breedTimers reset.
 //
 // Parameters:
 //
@@ -236,135 +411,281 @@ func UpdateFish(x int, y int) error {
 // Returns:
 //
 //	nil
-func UpdateSharks(x int, y int) error {
-	freeSquares := GatherFreeSquares(x, y)
-	fishSquares := GatherFishSquares(x, y)
-	newX, newY := x, y
-	if len(fishSquares) > 0 {
-		newPosition := rand.IntN(len(fishSquares))
-		newX = fishSquares[newPosition][0]
-		newY = fishSquares[newPosition][1]
-		if buffer[newX][newY].typeId != 2 {
-			buffer[newX][newY].typeId = 2
-			buffer[newX][newY].energy = grid[x][y].energy - 1 + energyGain
-			buffer[newX][newY].breedTimer = grid[x][y].breedTimer - 1
-		} else if len(freeSquares) > 0 {
-			newPosition := rand.IntN(len(freeSquares))
-			newX = freeSquares[newPosition][0]
-			newY = freeSquares[newPosition][1]
-			buffer[newX][newY].typeId = 2
-			buffer[newX][newY].energy = grid[x][y].energy - 1
-			buffer[newX][newY].breedTimer = grid[x][y].breedTimer - 1
-		} else {
-			buffer[x][y].typeId = grid[x][y].typeId
-			buffer[x][y].energy = grid[x][y].energy - 1
-			buffer[x][y].breedTimer = grid[x][y].breedTimer - 1
-			newX = x
-			newY = y
+func (w *World) UpdateSharks(x int, y int) error {
+	current := w.gridAt(x, y)
+	next := square{typeId: 2, energy: current.energy - 1, breedTimer: current.breedTimer - 1}
+	breeds := next.breedTimer <= 0
+	if breeds {
+		next.breedTimer = w.sharkBreed
+	}
+	moved := false
+	if next.energy+w.energyGain > 0 {
+		fishSquares := w.GatherFishSquares(x, y)
+		if len(fishSquares) > 0 {
+			newPosition := w.rng.IntN(len(fishSquares))
+			fed := next
+			fed.energy += w.energyGain
+			if w.moveTo(fishSquares[newPosition][0], fishSquares[newPosition][1], fed) {
+				moved = true
+				w.stats.Predations++
+			} else {
+				w.stats.BlockedMoves++
+			}
+		}
+	}
+	if !moved && next.energy <= 0 {
+		w.stats.Starvations++
+		return nil
+	}
+	if !moved {
+		freeSquares := w.GatherFreeSquares(x, y)
+		if len(freeSquares) > 0 {
+			newPosition := w.rng.IntN(len(freeSquares))
+			if w.moveTo(freeSquares[newPosition][0], freeSquares[newPosition][1], next) {
+				moved = true
+			} else {
+				w.stats.BlockedMoves++
+			}
 		}
-	} else if len(freeSquares) > 0 {
-		newPosition := rand.IntN(len(freeSquares))
-		newX = freeSquares[newPosition][0]
-		newY = freeSquares[newPosition][1]
-		buffer[newX][newY].typeId = 2
-		buffer[newX][newY].energy = grid[x][y].energy - 1
-		buffer[newX][newY].breedTimer = grid[x][y].breedTimer - 1
-	} else {
-		buffer[x][y].typeId = grid[x][y].typeId
-		buffer[x][y].energy = grid[x][y].energy - 1
-		buffer[x][y].breedTimer = grid[x][y].breedTimer - 1
 	}
-	if buffer[newX][newY].energy <= 0 {
-		buffer[newX][newY].typeId = 0
-		buffer[newX][newY].energy = 0
+	if !moved {
+		w.moveTo(x, y, next)
 		return nil
 	}
-	if buffer[newX][newY].breedTimer <= 0 {
-		buffer[x][y].typeId = 2
-		buffer[x][y].energy = starve
-		buffer[x][y].breedTimer = sharkBreed
-		buffer[newX][newY].breedTimer = sharkBreed
+	if breeds {
+		w.moveTo(x, y, square{typeId: 2, energy: w.starve, breedTimer: w.sharkBreed})
+		w.stats.SharkBirths++
 	}
 	return nil
 }
 
-// Update iterates through the grid (which represents the current state of the world), detects whether each cell
-// contains a fish or a shark and calls the relevant function. When the main Update loop is complete it sets grid
-// to be buffer (the now updated state of the world) and zeros the buffer.
+// moveTo writes an animal onto a square of the buffer, unless another animal has already moved there this
+// chronon. Animals are never overwritten, so none is lost to a collision
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//	next - the animal as it will be next chronon
 //
 // Returns:
 //
-//	nil
-func Update() error {
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			if grid[x][y].typeId == 1 {
-				UpdateFish(x, y)
-			} else if grid[x][y].typeId == 2 {
-				UpdateSharks(x, y)
-			}
-		}
+//	bool - true if the animal was written, false if the square was already taken
+func (w *World) moveTo(x int, y int, next square) bool {
+	if w.bufferAt(x, y).typeId != 0 {
+		return false
 	}
+	w.setBuffer(x, y, next)
+	return true
+}
 
-	grid = buffer
+// Update iterates through the grid (which represents the current state of the world) twice, first calling
+// UpdateSharks for every shark and then UpdateFish for every fish, so predation is settled before any fish moves.
+// When the main Update loop is complete it swaps grid with buffer (the now updated state of the world). In
+// deterministic mode DeterministicUpdate is called instead, and in asynchronous mode AsynchronousUpdate changes
+// grid in place with no swap. The buffer is never cleared, the swap moves every square still in it a generation out
+// of date instead. The fish and sharks in the new grid are then counted for Stats. While the world is sparse only
+// the squares on its list of live animals and their neighbours are visited, in the same order a scan would visit
+// them, so the result is the same either way. DeterministicUpdate still visits every square. With Config.Check
+// set the invariants are then checked.
+//
+// Returns:
+//
+//	error - every invariant that is broken when checking is on, nil otherwise
+func (w *World) Update() error {
+	var fishBefore, sharksBefore int
+	if w.check {
+		fishBefore, sharksBefore = w.Population()
+	}
+	w.stats = watorcommon.Stats{}
+	switch {
+	case w.asynchronous:
+		w.AsynchronousUpdate()
+	case w.deterministic:
+		w.DeterministicUpdate()
+		w.swap()
+	default:
+		w.visit(2, w.UpdateSharks)
+		w.visit(1, w.UpdateFish)
+		w.swap()
+	}
 
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			buffer[x][y] = square{}
-		}
+	if w.sparse {
+		w.stats.Fish, w.stats.Sharks = w.census()
+	} else {
+		w.stats.Fish, w.stats.Sharks = w.Population()
 	}
+	w.track(w.stats.Fish + w.stats.Sharks)
+	w.chronon++
+	w.stats.Chronon = w.chronon
 
+	if w.check {
+		return watorcommon.CheckInvariants(w, int(w.fishBreed), int(w.sharkBreed), fishBefore, sharksBefore, w.stats)
+	}
 	return nil
 }
 
-// Display draws the new grid after each Update loop
+// Seed returns the seed this world was created with, so that a run can be repeated exactly
 //
-// Parameters:
+// Returns:
 //
-//	window — the Ebiten image buffer used for drawing.
-func Display(window *ebiten.Image) {
-	window.Fill(blue)
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			for i := 0; i < scale; i++ {
-				for j := 0; j < scale; j++ {
-					if grid[x][y].typeId == 1 {
-						window.Set(x*scale+i, y*scale+j, yellow)
-					} else if grid[x][y].typeId == 2 {
-						window.Set(x*scale+i, y*scale+j, red)
-					}
-				}
+//	uint64 - the seed
+func (w *World) Seed() uint64 {
+	return w.seed
+}
+
+// Stats returns what happened during the most recent Update
+//
+// Returns:
+//
+//	watorcommon.Stats - the counts for the last chronon, all zero before the first Update
+func (w *World) Stats() watorcommon.Stats {
+	return w.stats
+}
+
+// Chronon returns how many simulation steps the world has taken
+//
+// Returns:
+//
+//	int - the number of completed Update calls
+func (w *World) Chronon() int {
+	return w.chronon
+}
+
+// Population counts the fish and sharks currently in the grid
+//
+// Returns:
+//
+//	fish int - the number of fish
+//	sharks int - the number of sharks
+func (w *World) Population() (fish int, sharks int) {
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			if w.gridAt(x, y).typeId == 1 {
+				fish++
+			} else if w.gridAt(x, y).typeId == 2 {
+				sharks++
 			}
 		}
 	}
+	return fish, sharks
+}
 
+// Size returns the dimensions of the grid
+//
+// Returns:
+//
+//	width int - the number of columns
+//	height int - the number of rows
+func (w *World) Size() (width int, height int) {
+	return w.width, w.height
 }
 
-// RunSequential initializes the grid and starts the sequential simulation loop
-func RunSequential() {
-	coords := [][2]int{}
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			coords = append(coords, [2]int{x, y})
+// Cell returns the square at (x, y)
+//
+// Parameters:
+//
+//	x - x coordinate of the square
+//	y - y coordinate of the square
+//
+// Returns:
+//
+//	watorcommon.Cell - what the square holds
+func (w *World) Cell(x int, y int) watorcommon.Cell {
+	current := w.gridAt(x, y)
+	return watorcommon.Cell{TypeId: int(current.typeId), Energy: int(current.energy), BreedTimer: int(current.breedTimer)}
+}
+
+// Snapshot captures everything needed to resume this world later with NewWorldFromSnapshot, in either engine
+//
+// Returns:
+//
+//	*watorcommon.Snapshot - the parameters, chronon, random number state and every cell of the grid
+func (w *World) Snapshot() *watorcommon.Snapshot {
+	state, _ := w.source.MarshalBinary()
+	snapshot := &watorcommon.Snapshot{
+		Config: watorcommon.Config{
+			Width:         w.width,
+			Height:        w.height,
+			NumFish:       w.numFish,
+			NumShark:      w.numShark,
+			FishBreed:     int(w.fishBreed),
+			SharkBreed:    int(w.sharkBreed),
+			Starve:        int(w.starve),
+			EnergyGain:    int(w.energyGain),
+			Threads:       w.threads,
+			Seed:          w.seed,
+			Deterministic: w.deterministic,
+			Asynchronous:  w.asynchronous,
+			Dense:         w.dense,
+			Check:         w.check,
+		},
+		Chronon: w.chronon,
+		RNG:     [][]byte{state},
+		Cells:   make([]watorcommon.Cell, w.width*w.height),
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			snapshot.Cells[x*w.height+y] = w.Cell(x, y)
 		}
 	}
-	rand.Shuffle(len(coords), func(i, j int) {
-		coords[i], coords[j] = coords[j], coords[i]
-	})
-	for i := 0; i < numFish; i++ {
-		x := coords[i][0]
-		y := coords[i][1]
-		grid[x][y].typeId = 1
-		grid[x][y].breedTimer = fishBreed
+	return snapshot
+}
+
+// Display draws the new grid after each Update loop. The grid is painted into a pixel buffer that is kept
+// between frames, then the whole buffer is uploaded to the window in one call
+//
+// Parameters:
+//
+//	window — the Ebiten image buffer used for drawing.
+//
+// Returns:
+//
+//	error - if the pixels cannot be uploaded
+func (w *World) Display(window *ebiten.Image) error {
+	if w.pixels == nil {
+		w.pixels = make([]byte, 4*w.width*scale*w.height*scale)
 	}
-	for i := numFish; i < numShark+numFish; i++ {
-		x := coords[i][0]
-		y := coords[i][1]
-		grid[x][y].typeId = 2
-		grid[x][y].breedTimer = sharkBreed
-		grid[x][y].energy = starve
+	stride := 4 * w.width * scale
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			watorcommon.PaintSquare(w.pixels, stride, x, y, scale, int(w.gridAt(x, y).typeId))
+		}
+	}
+	return window.ReplacePixels(w.pixels)
+}
+
+// Close releases what the world holds. A sequential world runs on the caller's goroutine and holds nothing, so
+// Close does nothing, but it lets both engines be closed the same way
+//
+// Returns:
+//
+//	error - always nil
+func (w *World) Close() error {
+	return nil
+}
+
+// Run opens a window and runs the sequential simulation loop on this world until the window is closed
+//
+// Returns:
+//
+//	error - if the window cannot be opened or Update fails
+func (w *World) Run() error {
+	log.Printf("Seed : %d", w.Seed())
+	return ebiten.Run(w.Frame, w.width*scale, w.height*scale, 1, "Wa-tor Simulation (Sequential)")
+}
+
+// RunSequential creates a world from the given parameters and starts the sequential simulation loop
+//
+// Parameters:
+//
+//	config - the simulation parameters
+func RunSequential(config watorcommon.Config) {
+	w, err := NewWorld(config)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := ebiten.Run(Frame, width, height, 1, "Wa-tor Simulation (Sequential)"); err != nil {
+	if err := w.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,93 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorsequential
+
+import watorcommon "help/common"
+
+// track decides with watorcommon.Sparse whether the next chronon visits only the live animals. A world that has
+// just turned sparse builds its list of live animals with one scan of the grid, and one that has turned dense
+// drops it. A world made with Config.Dense never turns sparse
+//
+// Parameters:
+//
+//	animals - the number of fish and sharks alive
+func (w *World) track(animals int) {
+	sparse := !w.dense && watorcommon.Sparse(w.sparse, animals, w.width*w.height)
+	if sparse && !w.sparse {
+		w.live = w.live[:0]
+		for x := 0; x < w.width; x++ {
+			for y := 0; y < w.height; y++ {
+				if w.gridAt(x, y).typeId != 0 {
+					w.live = append(w.live, x*w.height+y)
+				}
+			}
+		}
+	}
+	if !sparse {
+		w.live = w.live[:0]
+	}
+	w.sparse = sparse
+}
+
+// census counts the fish and sharks in a sparse world and brings the list of live animals up to date. Every
+// animal alive now is either one that was on the list or a baby or a mover on one of the neighbouring squares, so
+// only those squares are looked at
+//
+// Returns:
+//
+//	fish int - the number of fish
+//	sharks int - the number of sharks
+func (w *World) census() (fish int, sharks int) {
+	w.candidates = watorcommon.Neighbourhood(w.live, w.width, w.height, w.candidates)
+	w.live = w.live[:0]
+	for _, i := range w.candidates {
+		switch w.gridAt(i/w.height, i%w.height).typeId {
+		case 1:
+			fish++
+		case 2:
+			sharks++
+		default:
+			continue
+		}
+		w.live = append(w.live, i)
+	}
+	return fish, sharks
+}
+
+// visit calls update for every animal of one kind in the grid, in the order a scan of the grid finds them. A
+// sparse world only looks at the squares on its list of live animals
+//
+// Parameters:
+//
+//	typeId - the animals to visit, 2 for sharks and 1 for fish
+//	update - called with the coordinates of each animal
+func (w *World) visit(typeId uint8, update func(x int, y int) error) {
+	if w.sparse {
+		for _, i := range w.live {
+			if x, y := i/w.height, i%w.height; w.gridAt(x, y).typeId == typeId {
+				update(x, y)
+			}
+		}
+		return
+	}
+	for x := 0; x < w.width; x++ {
+		for y := 0; y < w.height; y++ {
+			if w.gridAt(x, y).typeId == typeId {
+				update(x, y)
+			}
+		}
+	}
+}
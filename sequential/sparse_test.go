@@ -0,0 +1,82 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package watorsequential_test
+
+import (
+	"reflect"
+	"testing"
+
+	watorcommon "help/common"
+	watorsequential "help/sequential"
+)
+
+// sparseConfig is a crowded world whose sharks eat nearly every fish, so it turns sparse within a few dozen
+// chronons and dense again once the fish come back
+func sparseConfig() watorcommon.Config {
+	return watorcommon.Config{
+		Width: 64, Height: 48, NumFish: 600, NumShark: 600, FishBreed: 4, SharkBreed: 6, Starve: 4, EnergyGain: 4,
+		Threads: 1, Seed: 5, Check: true,
+	}
+}
+
+// TestSparseMatchesDense runs each update scheme twice side by side, once free to turn sparse and once with
+// Config.Dense, and checks that the grids are the same every chronon while the world turns sparse and back
+func TestSparseMatchesDense(t *testing.T) {
+	schemes := []struct {
+		name   string
+		change func(config *watorcommon.Config)
+	}{
+		{"default", func(config *watorcommon.Config) {}},
+		{"asynchronous", func(config *watorcommon.Config) { config.Asynchronous = true }},
+		{"deterministic", func(config *watorcommon.Config) { config.Deterministic = true }},
+	}
+	for _, scheme := range schemes {
+		t.Run(scheme.name, func(t *testing.T) {
+			config := sparseConfig()
+			scheme.change(&config)
+			sparse, err := watorsequential.NewWorld(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Dense = true
+			dense, err := watorsequential.NewWorld(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cells := config.Width * config.Height
+			turnedSparse, turnedDense, wasSparse := false, false, false
+			for chronon := 1; chronon <= 60; chronon++ {
+				if err := sparse.Update(); err != nil {
+					t.Fatalf("sparse chronon %d: %v", chronon, err)
+				}
+				if err := dense.Update(); err != nil {
+					t.Fatalf("dense chronon %d: %v", chronon, err)
+				}
+				if !reflect.DeepEqual(sparse.Snapshot().Cells, dense.Snapshot().Cells) {
+					t.Fatalf("chronon %d: the grids differ", chronon)
+				}
+				stats := sparse.Stats()
+				isSparse := watorcommon.Sparse(wasSparse, stats.Fish+stats.Sharks, cells)
+				turnedSparse = turnedSparse || isSparse && !wasSparse
+				turnedDense = turnedDense || turnedSparse && !isSparse && wasSparse
+				wasSparse = isSparse
+			}
+			if !turnedSparse || !turnedDense {
+				t.Fatalf("the world did not turn sparse and dense again (sparse %v, dense %v)", turnedSparse, turnedDense)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	watorcommon "help/common"
+)
+
+// benchFormats are the table formats writeBenchTable knows
+var benchFormats = []string{"text", "csv", "markdown"}
+
+// benchColumns are the column names of the benchmark table
+var benchColumns = []string{
+	"engine", "scheduler", "threads", "mean_seconds_per_1000", "stddev_seconds_per_1000", "speedup", "efficiency",
+	"imbalance", "static_imbalance",
+}
+
+// measurement is the timing of one engine at one thread count over every repetition
+//
+// Fields:
+//
+//	engine		"sequential" or "concurrent"
+//	scheduler	how the concurrent engine shared out the grid, empty for the sequential engine
+//	threads		the number of threads, always 1 for the sequential engine
+//	times		the time per 1000 chronons of each repetition
+//	mean		the mean of times
+//	stddev		the sample standard deviation of times
+//	speedup		the sequential mean divided by this mean, 0 when there is no sequential baseline
+//	efficiency	speedup divided by threads, 0 when there is no sequential baseline
+//	imbalance	the mean Stats.Imbalance over every timed chronon, 0 for the sequential engine
+//	static		the mean Stats.StaticImbalance over every timed chronon
+type measurement struct {
+	engine     string
+	scheduler  string
+	threads    int
+	times      []time.Duration
+	mean       time.Duration
+	stddev     time.Duration
+	speedup    float64
+	efficiency float64
+	imbalance  float64
+	static     float64
+}
+
+// benchCommand times the simulation without a window. On its own it times the chosen engine. Given a list of
+// thread counts it times the sequential engine as a baseline and then the concurrent engine at each thread
+// count, reporting speedup and parallel efficiency against the baseline. Given a list of schedulers the concurrent
+// engine is timed with each of them, so they can be compared. Every run starts from the same ocean
+//
+// Parameters:
+//
+//	args - the command line arguments after "bench"
+//
+// Returns:
+//
+//	error - if the flags or parameters are invalid, Update fails or the table cannot be written
+func benchCommand(args []string) error {
+	flags, o := newFlagSet("bench", "Time the simulation without a window. With -threads-list, time the sequential "+
+		"engine and the concurrent engine at each thread count and report speedup and parallel efficiency.")
+	chronons := flags.Int("chronons", 1000, "number of chronons timed in each repetition")
+	warmup := flags.Int("warmup", 100, "number of chronons run before timing starts in each repetition")
+	repeat := flags.Int("repeat", 5, "number of timed repetitions for each engine and thread count")
+	threadsList := flags.String("threads-list", "", "comma separated thread counts to compare, e.g. 1,2,4,8")
+	schedulerList := flags.String("schedulers", "", "comma separated schedulers to compare, e.g. locked,phased")
+	format := flags.String("format", "text", "table format, text, csv or markdown")
+	flags.Parse(args)
+	if *chronons <= 0 || *warmup < 0 || *repeat <= 0 {
+		return fmt.Errorf("-chronons and -repeat must be positive and -warmup not negative")
+	}
+	if !slices.Contains(benchFormats, *format) {
+		return fmt.Errorf("unknown table format %q, want text, csv or markdown", *format)
+	}
+	threadCounts, err := parseThreads(*threadsList)
+	if err != nil {
+		return err
+	}
+	schedulers, err := parseSchedulers(*schedulerList, o.config.Scheduler)
+	if err != nil {
+		return err
+	}
+	snapshot, err := o.start()
+	if err != nil {
+		return err
+	}
+	o.config.Seed = watorcommon.PickSeed(o.config.Seed)
+	log.Printf("Seed : %d", o.config.Seed)
+	config := o.config
+	if snapshot != nil {
+		if !o.set("threads") {
+			config.Threads = snapshot.Config.Threads
+		}
+		snapshot.Config.Seed = watorcommon.PickSeed(snapshot.Config.Seed)
+		snapshot.Config.TileWidth = config.TileWidth
+		snapshot.Config.TileHeight = config.TileHeight
+		snapshot.Config.Balance = config.Balance
+		snapshot.Config.Dense = config.Dense
+		snapshot.Config.Check = config.Check
+	}
+	measure := func(engine string, threads int, scheduler watorcommon.Scheduler) (measurement, error) {
+		config.Threads = threads
+		config.Scheduler = scheduler
+		if snapshot != nil {
+			snapshot.Config.Threads = threads
+			snapshot.Config.Scheduler = scheduler
+		}
+		return benchmark(engine, config, snapshot, *warmup, *chronons, *repeat)
+	}
+	var table []measurement
+	if threadCounts == nil {
+		if o.engine == "sequential" {
+			schedulers = schedulers[:1]
+		}
+		for _, scheduler := range schedulers {
+			m, err := measure(o.engine, config.Threads, scheduler)
+			if err != nil {
+				return err
+			}
+			table = append(table, m)
+		}
+	} else {
+		baseline, err := measure("sequential", config.Threads, config.Scheduler)
+		if err != nil {
+			return err
+		}
+		table = append(table, baseline)
+		for _, scheduler := range schedulers {
+			for _, threads := range threadCounts {
+				m, err := measure("concurrent", threads, scheduler)
+				if err != nil {
+					return err
+				}
+				table = append(table, m)
+			}
+		}
+		for i := range table {
+			table[i].speedup = baseline.mean.Seconds() / table[i].mean.Seconds()
+			table[i].efficiency = table[i].speedup / float64(table[i].threads)
+		}
+	}
+	return writeBenchTable(os.Stdout, *format, table)
+}
+
+// parseThreads reads a comma separated list of thread counts
+//
+// Parameters:
+//
+//	list - the list, for example "1,2,4,8"
+//
+// Returns:
+//
+//	[]int - the thread counts, nil if list is empty
+//	error - if an entry is not a positive whole number
+func parseThreads(list string) ([]int, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var threadCounts []int
+	for _, entry := range strings.Split(list, ",") {
+		threads, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || threads <= 0 {
+			return nil, fmt.Errorf("-threads-list: %q is not a positive thread count", entry)
+		}
+		threadCounts = append(threadCounts, threads)
+	}
+	return threadCounts, nil
+}
+
+// parseSchedulers reads a comma separated list of schedulers
+//
+// Parameters:
+//
+//	list - the list, for example "locked,phased"
+//	fallback - the scheduler to use if list is empty
+//
+// Returns:
+//
+//	[]watorcommon.Scheduler - the schedulers, just fallback if list is empty
+//	error - if an entry is not a scheduler name
+func parseSchedulers(list string, fallback watorcommon.Scheduler) ([]watorcommon.Scheduler, error) {
+	if strings.TrimSpace(list) == "" {
+		return []watorcommon.Scheduler{fallback}, nil
+	}
+	var schedulers []watorcommon.Scheduler
+	for _, entry := range strings.Split(list, ",") {
+		var scheduler watorcommon.Scheduler
+		if err := scheduler.UnmarshalText([]byte(strings.TrimSpace(entry))); err != nil {
+			return nil, fmt.Errorf("-schedulers: %w", err)
+		}
+		schedulers = append(schedulers, scheduler)
+	}
+	return schedulers, nil
+}
+
+// benchmark times one engine at one thread count. Each repetition creates a fresh world, runs the warm-up
+// chronons untimed, then times the rest
+//
+// Parameters:
+//
+//	engine - "sequential" or "concurrent"
+//	config - the simulation parameters, used when there is no snapshot
+//	snapshot - the snapshot every repetition starts from, may be nil
+//	warmup - the number of untimed chronons before timing starts
+//	chronons - the number of timed chronons
+//	repeat - the number of repetitions
+//
+// Returns:
+//
+//	measurement - the timings and imbalance, without speedup or efficiency
+//	error - if the world cannot be created or Update fails
+func benchmark(engine string, config watorcommon.Config, snapshot *watorcommon.Snapshot, warmup int, chronons int,
+	repeat int) (measurement, error) {
+	m := measurement{engine: engine, scheduler: config.Scheduler.String(), threads: config.Threads}
+	if engine == "sequential" {
+		m.scheduler = ""
+		m.threads = 1
+	}
+	meter := &imbalanceMeter{}
+	for i := 0; i < repeat; i++ {
+		w, err := newEngine(engine, config, snapshot)
+		if err != nil {
+			return m, err
+		}
+		result, err := timeWorld(w, warmup, chronons, meter)
+		w.Close()
+		if err != nil {
+			return m, err
+		}
+		perThousand := result.Elapsed * 1000 / time.Duration(result.Chronons)
+		log.Printf("%s %s, %d threads, repetition %d : %s per 1000 chronons", engine, m.scheduler, m.threads, i+1,
+			perThousand)
+		m.times = append(m.times, perThousand)
+	}
+	m.mean, m.stddev = meanStddev(m.times)
+	if meter.chronons > 0 {
+		m.imbalance = meter.imbalance / float64(meter.chronons)
+		m.static = meter.static / float64(meter.chronons)
+	}
+	return m, nil
+}
+
+// timeWorld runs the warm-up chronons untimed, then times the rest
+//
+// Parameters:
+//
+//	w - the world to run
+//	warmup - chronons to run before timing starts
+//	chronons - chronons to time
+//	observers - told about every timed chronon
+//
+// Returns:
+//
+//	watorcommon.Result - the timed run
+//	error - if Update fails
+func timeWorld(w world, warmup int, chronons int, observers ...watorcommon.Observer) (watorcommon.Result, error) {
+	if warmup > 0 {
+		if _, err := watorcommon.RunHeadless(w, warmup, nil); err != nil {
+			return watorcommon.Result{}, err
+		}
+	}
+	runtime.GC()
+	return watorcommon.RunHeadless(w, chronons, nil, observers...)
+}
+
+// imbalanceMeter adds up the imbalance of every chronon it observes
+//
+// Fields:
+//
+//	chronons	the number of chronons observed
+//	imbalance	the sum of their Stats.Imbalance
+//	static		the sum of their Stats.StaticImbalance
+type imbalanceMeter struct {
+	chronons  int
+	imbalance float64
+	static    float64
+}
+
+// Observe adds the imbalance of the chronon the engine has just finished
+//
+// Parameters:
+//
+//	engine - the world being run
+//
+// Returns:
+//
+//	error - always nil
+func (m *imbalanceMeter) Observe(engine watorcommon.Engine) error {
+	stats := engine.Stats()
+	m.chronons++
+	m.imbalance += stats.Imbalance
+	m.static += stats.StaticImbalance
+	return nil
+}
+
+// meanStddev returns the mean and sample standard deviation of a list of times
+//
+// Parameters:
+//
+//	times - at least one time
+//
+// Returns:
+//
+//	mean time.Duration - the mean
+//	stddev time.Duration - the sample standard deviation, 0 for a single time
+func meanStddev(times []time.Duration) (mean time.Duration, stddev time.Duration) {
+	var sum float64
+	for _, t := range times {
+		sum += float64(t)
+	}
+	average := sum / float64(len(times))
+	if len(times) < 2 {
+		return time.Duration(average), 0
+	}
+	var squares float64
+	for _, t := range times {
+		squares += (float64(t) - average) * (float64(t) - average)
+	}
+	return time.Duration(average), time.Duration(math.Sqrt(squares / float64(len(times)-1)))
+}
+
+// writeBenchTable writes the benchmark table. Speedup and efficiency are left blank when there is no sequential
+// baseline, and the imbalances for the sequential engine
+//
+// Parameters:
+//
+//	out - where the table is written
+//	format - "text" for aligned columns, "csv" for a spreadsheet or "markdown" for the README
+//	table - one measurement per row
+//
+// Returns:
+//
+//	error - if the format is not recognised or writing fails
+func writeBenchTable(out io.Writer, format string, table []measurement) error {
+	rows := make([][]string, len(table))
+	for i, m := range table {
+		rows[i] = []string{
+			m.engine,
+			m.scheduler,
+			strconv.Itoa(m.threads),
+			strconv.FormatFloat(m.mean.Seconds(), 'f', 3, 64),
+			strconv.FormatFloat(m.stddev.Seconds(), 'f', 3, 64),
+			"",
+			"",
+			"",
+			"",
+		}
+		if m.speedup > 0 {
+			rows[i][5] = strconv.FormatFloat(m.speedup, 'f', 2, 64)
+			rows[i][6] = strconv.FormatFloat(m.efficiency, 'f', 2, 64)
+		}
+		if m.imbalance > 0 {
+			rows[i][7] = strconv.FormatFloat(m.imbalance, 'f', 2, 64)
+			rows[i][8] = strconv.FormatFloat(m.static, 'f', 2, 64)
+		}
+	}
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write(benchColumns)
+		writer.WriteAll(rows)
+		return writer.Error()
+	case "markdown":
+		fmt.Fprintf(out, "| %s |\n", strings.Join(benchColumns, " | "))
+		fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", len(benchColumns)))
+		for _, row := range rows {
+			if _, err := fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "text":
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.Join(benchColumns, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("unknown table format %q, want text, csv or markdown", format)
+}
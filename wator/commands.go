@@ -0,0 +1,206 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	watorcommon "help/common"
+)
+
+// runCommand opens a window and runs the simulation until the window is closed
+//
+// Parameters:
+//
+//	args - the command line arguments after "run"
+//
+// Returns:
+//
+//	error - if the world cannot be created or the window fails
+func runCommand(args []string) error {
+	flags, o := newFlagSet("run", "Open a window and run the simulation until the window is closed.")
+	flags.Parse(args)
+	w, err := o.newWorld()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return w.Run()
+}
+
+// headlessCommand runs the simulation without a window, optionally streaming the stats of every chronon to a file
+// and saving a snapshot at the end
+//
+// Parameters:
+//
+//	args - the command line arguments after "headless"
+//
+// Returns:
+//
+//	error - if the world cannot be created, Update fails or a file cannot be written
+func headlessCommand(args []string) error {
+	flags, o := newFlagSet("headless", "Run the simulation without a window.")
+	chronons := flags.Int("chronons", 1000, "number of chronons to run, 0 for no limit")
+	untilExtinct := flags.Bool("until-extinct", false, "stop once the fish or the sharks have died out")
+	statsPath := flags.String("stats", "", "file to write the stats of every chronon to, .csv or .jsonl")
+	savePath := flags.String("save", "", "file to save a snapshot of the world to when the run ends")
+	flags.Parse(args)
+	w, err := o.newWorld()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	var observers []watorcommon.Observer
+	var statsFile *os.File
+	if *statsPath != "" {
+		if statsFile, err = os.Create(*statsPath); err != nil {
+			return err
+		}
+		series, err := watorcommon.NewSeriesWriter(statsFile, watorcommon.SeriesFormat(*statsPath))
+		if err != nil {
+			statsFile.Close()
+			return err
+		}
+		observers = append(observers, series)
+	}
+	var stop watorcommon.StopFunc
+	if *untilExtinct {
+		stop = watorcommon.Extinct
+	}
+	log.Printf("Seed : %d", w.Seed())
+	result, err := watorcommon.RunHeadless(w, *chronons, stop, observers...)
+	// A failed Close can mean the stats never reached the disk, so it is reported like a failed Write
+	if statsFile != nil {
+		if closeErr := statsFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	printResult(os.Stdout, result)
+	if *savePath != "" {
+		return saveSnapshot(*savePath, w)
+	}
+	return nil
+}
+
+// renderCommand runs the simulation without a window, saving PNG frames, an animated GIF or both. The starting
+// world is always the first frame
+//
+// Parameters:
+//
+//	args - the command line arguments after "render"
+//
+// Returns:
+//
+//	error - if -frames is not a usable pattern, the world cannot be created, Update fails or an image cannot be written
+func renderCommand(args []string) error {
+	flags, o := newFlagSet("render", "Run the simulation without a window, saving PNG frames or an animated GIF.")
+	chronons := flags.Int("chronons", 100, "number of chronons to run")
+	every := flags.Int("every", 10, "number of chronons between frames")
+	scale := flags.Int("scale", watorcommon.Scale, "size of each square in pixels")
+	frames := flags.String("frames", "", "file name of each PNG frame, with %d for the chronon, e.g. frames/wator-%06d.png")
+	gifPath := flags.String("gif", "", "file to write an animated GIF of the run to")
+	delay := flags.Int("delay", 10, "time each GIF frame is shown, in hundredths of a second")
+	flags.Parse(args)
+	if *frames == "" && *gifPath == "" {
+		return errors.New("render needs -frames, -gif or both")
+	}
+	if *scale <= 0 {
+		return fmt.Errorf("-scale must be positive, got %d", *scale)
+	}
+	var png *watorcommon.PNGWriter
+	if *frames != "" {
+		var err error
+		if png, err = watorcommon.NewPNGWriter(*frames, *every, *scale); err != nil {
+			return err
+		}
+	}
+	w, err := o.newWorld()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	var observers []watorcommon.Observer
+	if png != nil {
+		if err := png.Write(w, w.Chronon()); err != nil {
+			return err
+		}
+		observers = append(observers, png)
+	}
+	var animation *watorcommon.GIFWriter
+	var gifFile *os.File
+	if *gifPath != "" {
+		if gifFile, err = os.Create(*gifPath); err != nil {
+			return err
+		}
+		animation = watorcommon.NewGIFWriter(gifFile, *every, *scale, *delay)
+		animation.Write(w)
+		observers = append(observers, animation)
+	}
+	log.Printf("Seed : %d", w.Seed())
+	result, err := watorcommon.RunHeadless(w, *chronons, nil, observers...)
+	if err == nil {
+		printResult(os.Stdout, result)
+		if animation != nil {
+			err = animation.Close()
+		}
+	}
+	if gifFile != nil {
+		if closeErr := gifFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// printResult writes a one line summary of a headless run
+//
+// Parameters:
+//
+//	out - where the summary is written
+//	result - the run to summarise
+func printResult(out io.Writer, result watorcommon.Result) {
+	fmt.Fprintf(out, "chronons: %d, fish: %d, sharks: %d, stopped early: %t, elapsed: %s\n",
+		result.Chronons, result.Fish, result.Sharks, result.Stopped, result.Elapsed)
+}
+
+// saveSnapshot writes a snapshot of the world to a file
+//
+// Parameters:
+//
+//	path - the file to write
+//	w - the world to save
+//
+// Returns:
+//
+//	error - if the file cannot be written
+func saveSnapshot(path string, w world) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := watorcommon.Save(file, w.Snapshot()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Command wator runs the Wa-Tor simulation with either the sequential or the concurrent engine
+//
+// Usage:
+//
+//	wator <command> [flags]
+//
+// Commands:
+//
+//	run			open a window and run the simulation
+//	headless	run without a window, optionally writing stats and a snapshot
+//	bench		time the simulation without a window
+//	render		run without a window, saving PNG frames or an animated GIF
+//
+// Run "wator <command> -h" for the flags a command takes
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// commands maps each subcommand name to the function that runs it with the remaining arguments
+var commands = map[string]func(args []string) error{
+	"run":      runCommand,
+	"headless": headlessCommand,
+	"bench":    benchCommand,
+	"render":   renderCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "wator: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// usage prints the list of subcommands to standard error
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage: wator <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+name)
+	}
+	fmt.Fprintln(os.Stderr, "run \"wator <command> -h\" for the flags a command takes")
+}
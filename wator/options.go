@@ -0,0 +1,258 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	watorcommon "help/common"
+	watorconcurrent "help/concurrent"
+	watorsequential "help/sequential"
+)
+
+// world is what the subcommands need from either engine's World
+type world interface {
+	watorcommon.Engine
+	Seed() uint64
+	Snapshot() *watorcommon.Snapshot
+	Run() error
+	Close() error
+}
+
+// options holds the flags every subcommand shares
+//
+// Fields:
+//
+//	engine		"sequential" or "concurrent"
+//	config		the simulation parameters
+//	image		a PNG to start from instead of a random ocean
+//	snapshot	a snapshot to resume from instead of a random ocean
+//	flags		the flag set the options were registered on
+type options struct {
+	engine   string
+	config   watorcommon.Config
+	image    string
+	snapshot string
+	flags    *flag.FlagSet
+}
+
+// newFlagSet creates the flag set for a subcommand with every simulation parameter already registered, defaulting
+// to watorcommon.DefaultConfig
+//
+// Parameters:
+//
+//	name - the subcommand
+//	description - one line describing what the subcommand does, shown by -h
+//
+// Returns:
+//
+//	*flag.FlagSet - the flag set, for the subcommand to add its own flags to before parsing
+//	*options - filled in when the flag set is parsed
+func newFlagSet(name string, description string) (*flag.FlagSet, *options) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: wator %s [flags]\n%s\n", name, description)
+		flags.PrintDefaults()
+	}
+	o := &options{config: watorcommon.DefaultConfig(), flags: flags}
+	flags.StringVar(&o.engine, "engine", "concurrent", "which engine to run, sequential or concurrent")
+	flags.IntVar(&o.config.Width, "width", o.config.Width, "number of columns in the grid")
+	flags.IntVar(&o.config.Height, "height", o.config.Height, "number of rows in the grid")
+	flags.IntVar(&o.config.NumFish, "fish", o.config.NumFish, "number of fish to start with")
+	flags.IntVar(&o.config.NumShark, "sharks", o.config.NumShark, "number of sharks to start with")
+	flags.IntVar(&o.config.FishBreed, "fish-breed", o.config.FishBreed, "chronons a fish lives before breeding")
+	flags.IntVar(&o.config.SharkBreed, "shark-breed", o.config.SharkBreed, "chronons a shark lives before breeding")
+	flags.IntVar(&o.config.Starve, "starve", o.config.Starve, "chronons a shark survives without eating")
+	flags.IntVar(&o.config.EnergyGain, "energy-gain", o.config.EnergyGain, "energy a shark gains from eating a fish")
+	flags.IntVar(&o.config.Threads, "threads", o.config.Threads, "number of threads the concurrent engine uses")
+	flags.Uint64Var(&o.config.Seed, "seed", o.config.Seed, "seed for every random choice, 0 for a random seed")
+	flags.BoolVar(&o.config.Deterministic, "deterministic", o.config.Deterministic,
+		"use the update that gives the same result for any number of threads")
+	flags.BoolVar(&o.config.Asynchronous, "asynchronous", o.config.Asynchronous,
+		"move animals one at a time in a random order, in place, as in Dewdney's original Wa-Tor")
+	flags.TextVar(&o.config.Scheduler, "scheduler", o.config.Scheduler,
+		"how the concurrent engine shares out the grid, locked, phased or halo")
+	flags.IntVar(&o.config.TileWidth, "tile-width", o.config.TileWidth,
+		"width of the blocks the locked scheduler sweeps, 0 with -tile-height 0 for one block per thread")
+	flags.IntVar(&o.config.TileHeight, "tile-height", o.config.TileHeight,
+		"height of the blocks the locked scheduler sweeps, 0 with -tile-width 0 for one block per thread")
+	flags.BoolVar(&o.config.Balance, "balance", o.config.Balance,
+		"let the locked scheduler's idle workers take blocks from busy ones")
+	flags.BoolVar(&o.config.Dense, "dense", o.config.Dense,
+		"always scan the whole grid, even when few enough squares are occupied to visit only the animals")
+	flags.BoolVar(&o.config.Check, "check", o.config.Check,
+		"verify the invariants after every chronon and stop at the first one broken, slow")
+	flags.StringVar(&o.image, "image", "", "PNG to start from, blue water, yellow fish and red sharks")
+	flags.StringVar(&o.snapshot, "snapshot", "", "snapshot to resume from")
+	return flags, o
+}
+
+// set reports whether a flag was given on the command line rather than left at its default
+//
+// Parameters:
+//
+//	name - the flag
+//
+// Returns:
+//
+//	bool - true if the flag was given
+func (o *options) set(name string) bool {
+	found := false
+	o.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// newWorld creates a world with the chosen engine. It starts from the snapshot or image if one was given and from
+// a random ocean otherwise. A snapshot keeps its own parameters, apart from the thread count when -threads is given
+// and the scheduler, tile size, balancing, dense scanning and checking, which snapshots do not record
+//
+// Returns:
+//
+//	world - the new world
+//	error - if the parameters are invalid or a file cannot be read
+func (o *options) newWorld() (world, error) {
+	snapshot, err := o.start()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		if o.set("threads") {
+			snapshot.Config.Threads = o.config.Threads
+		}
+		snapshot.Config.Scheduler = o.config.Scheduler
+		snapshot.Config.TileWidth = o.config.TileWidth
+		snapshot.Config.TileHeight = o.config.TileHeight
+		snapshot.Config.Balance = o.config.Balance
+		snapshot.Config.Dense = o.config.Dense
+		snapshot.Config.Check = o.config.Check
+	}
+	return newEngine(o.engine, o.config, snapshot)
+}
+
+// start loads the snapshot or image the world should start from
+//
+// Returns:
+//
+//	*watorcommon.Snapshot - the starting world, nil for a random ocean
+//	error - if both -snapshot and -image were given or the file cannot be read
+func (o *options) start() (*watorcommon.Snapshot, error) {
+	if o.snapshot != "" && o.image != "" {
+		return nil, fmt.Errorf("-snapshot and -image cannot be used together")
+	}
+	if o.snapshot != "" {
+		file, err := os.Open(o.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		snapshot, err := watorcommon.Load(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.snapshot, err)
+		}
+		return snapshot, nil
+	}
+	if o.image != "" {
+		file, err := os.Open(o.image)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		snapshot, err := watorcommon.LoadImage(file, o.config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.image, err)
+		}
+		return snapshot, nil
+	}
+	return nil, nil
+}
+
+// newEngine creates a world with the named engine
+//
+// Parameters:
+//
+//	engine - "sequential" or "concurrent"
+//	config - the simulation parameters, used when there is no snapshot
+//	snapshot - the snapshot to resume from, may be nil
+//
+// Returns:
+//
+//	world - the new world
+//	error - if the engine is unknown or the parameters or snapshot are invalid
+func newEngine(engine string, config watorcommon.Config, snapshot *watorcommon.Snapshot) (world, error) {
+	switch engine {
+	case "sequential":
+		return newSequential(config, snapshot)
+	case "concurrent":
+		return newConcurrent(config, snapshot)
+	}
+	return nil, fmt.Errorf("unknown engine %q, want sequential or concurrent", engine)
+}
+
+// newSequential creates a sequential world from a snapshot if there is one and from config otherwise
+//
+// Parameters:
+//
+//	config - the simulation parameters
+//	snapshot - the snapshot to resume from, may be nil
+//
+// Returns:
+//
+//	world - the new world
+//	error - if the parameters or snapshot are invalid
+func newSequential(config watorcommon.Config, snapshot *watorcommon.Snapshot) (world, error) {
+	var w *watorsequential.World
+	var err error
+	if snapshot != nil {
+		w, err = watorsequential.NewWorldFromSnapshot(snapshot)
+	} else {
+		w, err = watorsequential.NewWorld(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// newConcurrent creates a concurrent world from a snapshot if there is one and from config otherwise
+//
+// Parameters:
+//
+//	config - the simulation parameters
+//	snapshot - the snapshot to resume from, may be nil
+//
+// Returns:
+//
+//	world - the new world
+//	error - if the parameters or snapshot are invalid
+func newConcurrent(config watorcommon.Config, snapshot *watorcommon.Snapshot) (world, error) {
+	var w *watorconcurrent.World
+	var err error
+	if snapshot != nil {
+		w, err = watorconcurrent.NewWorldFromSnapshot(snapshot)
+	} else {
+		w, err = watorconcurrent.NewWorld(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
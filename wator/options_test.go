@@ -0,0 +1,73 @@
+// Copyright (C) 2025  Diarmuid O'Neill
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	watorcommon "help/common"
+)
+
+// writeBadSnapshot saves a snapshot holding a fish whose breed timer is far above FishBreed, which CheckCell
+// rejects
+//
+// Parameters:
+//
+//	t - the test, failed if the file cannot be written
+//
+// Returns:
+//
+//	string - the path of the snapshot
+func writeBadSnapshot(t *testing.T) string {
+	t.Helper()
+	config := watorcommon.Config{
+		Width: 8, Height: 8, NumFish: 1, FishBreed: 3, SharkBreed: 10, Starve: 4, EnergyGain: 2, Threads: 1, Seed: 1,
+	}
+	cells := make([]watorcommon.Cell, config.Width*config.Height)
+	cells[0] = watorcommon.Cell{TypeId: 1, BreedTimer: 100}
+	path := filepath.Join(t.TempDir(), "bad.wtor")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watorcommon.Save(file, &watorcommon.Snapshot{Config: config, Cells: cells}); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestCheckAppliesToSnapshots resumes a snapshot that breaks an invariant, which must only be reported when
+// -check is given, on both engines
+func TestCheckAppliesToSnapshots(t *testing.T) {
+	path := writeBadSnapshot(t)
+	for _, engine := range []string{"sequential", "concurrent"} {
+		t.Run(engine, func(t *testing.T) {
+			args := []string{"-engine", engine, "-snapshot", path, "-chronons", "3"}
+			if err := headlessCommand(args); err != nil {
+				t.Fatalf("without -check: %v", err)
+			}
+			if err := headlessCommand(append(args, "-check")); !errors.Is(err, watorcommon.ErrInvariant) {
+				t.Fatalf("with -check: got %v, want %v", err, watorcommon.ErrInvariant)
+			}
+		})
+	}
+}